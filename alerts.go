@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// alertMarkerPattern matches the `[!NOTE]` line that turns a blockquote
+// into an alert, anything after the marker is used as the title
+var alertMarkerPattern = regexp.MustCompile(`^\[!([A-Za-z][\w-]*)\][ \t]*(.*)$`)
+
+// AlertConfig is the `alerts` key of the markdown config
+type AlertConfig struct {
+	// prefix for all the classes of the alert markup
+	Class string               `json:"class"`
+	Types map[string]AlertType `json:"types"`
+}
+
+// AlertType is a kind of alert, types other than the
+// ones from GitHub can be added in the config
+type AlertType struct {
+	Title string `json:"title"`
+	// replaces the `<class>-<type>` class of the alert
+	Class string `json:"class"`
+}
+
+var defaultAlertTypes = map[string]AlertType{
+	"note":      {Title: "Note"},
+	"tip":       {Title: "Tip"},
+	"important": {Title: "Important"},
+	"warning":   {Title: "Warning"},
+	"caution":   {Title: "Caution"},
+}
+
+// KindAlert is the node kind of alerts
+var KindAlert = ast.NewNodeKind("Alert")
+
+// Alert is a blockquote that starts with a `[!TYPE]` marker
+type Alert struct {
+	ast.BaseBlock
+	AlertType string
+	Title     string
+}
+
+func (n *Alert) Kind() ast.NodeKind {
+	return KindAlert
+}
+
+func (n *Alert) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{
+		"AlertType": n.AlertType,
+		"Title":     n.Title,
+	}, nil)
+}
+
+type alertExtension struct {
+	config AlertConfig
+}
+
+// newAlertExtension renders GitHub style alerts as callouts
+//
+//	> [!WARNING]
+//	> Careful now
+func newAlertExtension(config AlertConfig) goldmark.Extender {
+	if config.Class == "" {
+		config.Class = "markdown-alert"
+	}
+	types := map[string]AlertType{}
+	for name, alertType := range defaultAlertTypes {
+		types[name] = alertType
+	}
+	for name, alertType := range config.Types {
+		name = strings.ToLower(name)
+		if alertType.Title == "" {
+			alertType.Title = types[name].Title
+		}
+		if alertType.Title == "" {
+			alertType.Title = humanize(name)
+		}
+		types[name] = alertType
+	}
+	config.Types = types
+
+	return &alertExtension{config: config}
+}
+
+func (e *alertExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(
+		util.Prioritized(&alertTransformer{config: e.config}, 500),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&alertRenderer{config: e.config}, 500),
+	))
+}
+
+type alertTransformer struct {
+	config AlertConfig
+}
+
+func (t *alertTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+
+	blockquotes := []*ast.Blockquote{}
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if bq, ok := n.(*ast.Blockquote); ok && entering {
+			blockquotes = append(blockquotes, bq)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, bq := range blockquotes {
+		para, ok := bq.FirstChild().(*ast.Paragraph)
+		if !ok || para.Lines().Len() == 0 {
+			continue
+		}
+
+		firstLine := para.Lines().At(0)
+		match := alertMarkerPattern.FindSubmatch(bytes.TrimSpace(firstLine.Value(source)))
+		if match == nil {
+			continue
+		}
+		alertName := strings.ToLower(string(match[1]))
+		alertType, ok := t.config.Types[alertName]
+		if !ok {
+			continue
+		}
+
+		alert := &Alert{
+			AlertType: alertName,
+			Title:     alertType.Title,
+		}
+		if customTitle := strings.TrimSpace(string(match[2])); customTitle != "" {
+			alert.Title = customTitle
+		}
+
+		removeFirstLine(para, firstLine.Stop)
+		if para.ChildCount() == 0 {
+			bq.RemoveChild(bq, para)
+		}
+
+		for child := bq.FirstChild(); child != nil; {
+			next := child.NextSibling()
+			alert.AppendChild(alert, child)
+			child = next
+		}
+		bq.Parent().ReplaceChild(bq.Parent(), bq, alert)
+	}
+}
+
+// removeFirstLine drops the inline nodes of the paragraph that
+// start before the end of its first line
+func removeFirstLine(para *ast.Paragraph, lineStop int) {
+	for child := para.FirstChild(); child != nil; {
+		next := child.NextSibling()
+		start := inlineStart(child)
+		if start == -1 || start >= lineStop {
+			break
+		}
+		if textNode, ok := child.(*ast.Text); ok && textNode.Segment.Stop > lineStop {
+			textNode.Segment = textNode.Segment.WithStart(lineStop)
+			break
+		}
+		para.RemoveChild(para, child)
+		child = next
+	}
+
+	lines := text.NewSegments()
+	for i := 1; i < para.Lines().Len(); i++ {
+		lines.Append(para.Lines().At(i))
+	}
+	para.SetLines(lines)
+}
+
+// inlineStart finds the source position of an inline node
+// from the first text in it
+func inlineStart(n ast.Node) int {
+	start := -1
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if textNode, ok := child.(*ast.Text); ok && entering {
+			start = textNode.Segment.Start
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return start
+}
+
+type alertRenderer struct {
+	config AlertConfig
+}
+
+func (r *alertRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindAlert, r.renderAlert)
+}
+
+func (r *alertRenderer) renderAlert(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*Alert)
+	if !entering {
+		_, _ = w.WriteString("</div>\n")
+		return ast.WalkContinue, nil
+	}
+
+	typeClass := r.config.Types[n.AlertType].Class
+	if typeClass == "" {
+		typeClass = r.config.Class + "-" + n.AlertType
+	}
+
+	_, _ = w.WriteString(`<div class="`)
+	_, _ = w.Write(util.EscapeHTML([]byte(r.config.Class + " " + typeClass)))
+	_, _ = w.WriteString(`">` + "\n")
+	_, _ = w.WriteString(`<p class="`)
+	_, _ = w.Write(util.EscapeHTML([]byte(r.config.Class + "-title")))
+	_, _ = w.WriteString(`">`)
+	_, _ = w.Write(util.EscapeHTML([]byte(n.Title)))
+	_, _ = w.WriteString("</p>\n")
+	return ast.WalkContinue, nil
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/barelyhuman/go/color"
+	"golang.org/x/net/html"
+)
+
+// linkAttributes are the attributes of each element
+// that point to another file
+var linkAttributes = map[string][]string{
+	"a":      {"href"},
+	"area":   {"href"},
+	"link":   {"href"},
+	"img":    {"src"},
+	"script": {"src"},
+	"iframe": {"src"},
+	"source": {"src"},
+	"video":  {"src", "poster"},
+	"audio":  {"src"},
+	"track":  {"src"},
+	"embed":  {"src"},
+}
+
+// builtPage is an HTML file from the output
+// with the links and ids found in it
+type builtPage struct {
+	links []string
+	ids   map[string]bool
+}
+
+// LinkIssue is a link that couldn't be resolved
+type LinkIssue struct {
+	Link    string
+	Message string
+}
+
+// runCheckLinks goes through the built site in outPath and reports
+// links to files and anchors that don't exist, external links are
+// only requested when asked to. Returns the exit code for the process
+func runCheckLinks(outPath string, external bool) int {
+	pages := map[string]*builtPage{}
+	files := map[string]bool{}
+
+	err := filepath.WalkDir(outPath, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(outPath, filePath)
+		if err != nil {
+			return err
+		}
+		urlPath := "/" + filepath.ToSlash(rel)
+		files[urlPath] = true
+
+		if filepath.Ext(filePath) != ".html" {
+			return nil
+		}
+		page, err := parseBuiltPage(filePath)
+		if err != nil {
+			return fmt.Errorf("%v: %v", filePath, err)
+		}
+		pages[urlPath] = page
+		return nil
+	})
+	bail(err)
+
+	basePath := "/"
+	if parsed, err := url.Parse(baseurl); err == nil && parsed.Path != "" {
+		basePath = parsed.Path
+	}
+	if !strings.HasSuffix(basePath, "/") {
+		basePath += "/"
+	}
+
+	issues := map[string][]LinkIssue{}
+	externalLinks := map[string][]string{}
+	linkCount := 0
+
+	for pagePath, page := range pages {
+		for _, link := range page.links {
+			linkCount++
+			parsed, err := url.Parse(strings.TrimSpace(link))
+			if err != nil {
+				issues[pagePath] = append(issues[pagePath], LinkIssue{link, "invalid url"})
+				continue
+			}
+
+			// links to the site with the full baseurl are internal
+			if strings.HasPrefix(link, baseurl) && strings.Contains(baseurl, "://") {
+				parsed, _ = url.Parse(basePath + strings.TrimPrefix(link, baseurl))
+			}
+
+			switch parsed.Scheme {
+			case "":
+			case "http", "https":
+				externalLinks[link] = append(externalLinks[link], pagePath)
+				continue
+			default:
+				// mailto:, tel: and the likes
+				continue
+			}
+			if parsed.Host != "" {
+				externalLinks[link] = append(externalLinks[link], pagePath)
+				continue
+			}
+
+			if message := checkInternalLink(pagePath, parsed, basePath, files, pages); message != "" {
+				issues[pagePath] = append(issues[pagePath], LinkIssue{link, message})
+			}
+		}
+	}
+
+	if external {
+		results := checkExternalLinks(externalLinks)
+		links := []string{}
+		for link := range results {
+			links = append(links, link)
+		}
+		sort.Strings(links)
+
+		for _, link := range links {
+			message := results[link]
+			if message == "" {
+				continue
+			}
+			for _, source := range externalLinks[link] {
+				issues[source] = append(issues[source], LinkIssue{link, message})
+			}
+		}
+	}
+
+	cs := &color.ColorString{}
+	if len(issues) == 0 {
+		fmt.Println(cs.Blue(logPrefix).Green("Checked ").Cyan(fmt.Sprint(linkCount)).Green(fmt.Sprintf(" link(s) in %v page(s), no broken links found", len(pages))).String())
+		return 0
+	}
+
+	pagePaths := []string{}
+	for pagePath := range issues {
+		pagePaths = append(pagePaths, pagePath)
+	}
+	sort.Strings(pagePaths)
+
+	brokenCount := 0
+	for _, pagePath := range pagePaths {
+		report := &color.ColorString{}
+		fmt.Fprintln(os.Stderr, report.Red(logPrefix).Reset("").Cyan(filepath.Join(outPath, filepath.FromSlash(pagePath))).String())
+		for _, issue := range issues[pagePath] {
+			brokenCount++
+			fmt.Fprintf(os.Stderr, "  %v: %v\n", issue.Link, issue.Message)
+		}
+	}
+
+	summary := &color.ColorString{}
+	fmt.Fprintln(os.Stderr, summary.Red(logPrefix).Red(fmt.Sprintf("found %v broken link(s) in %v page(s)", brokenCount, len(pagePaths))).String())
+	return 1
+}
+
+// parseBuiltPage collects the links and ids of an HTML file
+func parseBuiltPage(filePath string) (*builtPage, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	page := &builtPage{
+		ids: map[string]bool{},
+	}
+
+	tokenizer := html.NewTokenizer(file)
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			if tokenizer.Err() == io.EOF {
+				return page, nil
+			}
+			return nil, tokenizer.Err()
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.Data == "link" && isHintLink(token) {
+				continue
+			}
+			for _, attr := range token.Attr {
+				switch {
+				case attr.Key == "id", token.Data == "a" && attr.Key == "name":
+					page.ids[attr.Val] = true
+				}
+				for _, key := range linkAttributes[token.Data] {
+					if attr.Key == key && attr.Val != "" {
+						page.links = append(page.links, attr.Val)
+					}
+				}
+			}
+		}
+	}
+}
+
+// isHintLink is true for `<link>` elements that only tell the
+// browser to connect early, they don't point to an actual file
+func isHintLink(token html.Token) bool {
+	for _, attr := range token.Attr {
+		if attr.Key != "rel" {
+			continue
+		}
+		for _, rel := range strings.Fields(strings.ToLower(attr.Val)) {
+			if rel == "preconnect" || rel == "dns-prefetch" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkInternalLink resolves the link against the page it's in and
+// returns what's wrong with it, an empty message if nothing is
+func checkInternalLink(pagePath string, link *url.URL, basePath string, files map[string]bool, pages map[string]*builtPage) string {
+	target := pagePath
+	if link.Path != "" {
+		linkPath := link.Path
+		if !strings.HasPrefix(linkPath, "/") {
+			linkPath = path.Join(path.Dir(pagePath), linkPath)
+			if strings.HasSuffix(link.Path, "/") && !strings.HasSuffix(linkPath, "/") {
+				linkPath += "/"
+			}
+		} else {
+			if linkPath+"/" == basePath {
+				linkPath = basePath
+			}
+			if !strings.HasPrefix(linkPath, basePath) {
+				return fmt.Sprintf("outside of the baseurl `%v`", baseurl)
+			}
+			linkPath = "/" + strings.TrimPrefix(linkPath, basePath)
+		}
+
+		switch {
+		case strings.HasSuffix(linkPath, "/") && files[linkPath+"index.html"]:
+			target = linkPath + "index.html"
+		case files[linkPath]:
+			target = linkPath
+		case files[strings.TrimSuffix(linkPath, "/")+"/index.html"]:
+			target = strings.TrimSuffix(linkPath, "/") + "/index.html"
+		default:
+			return "no such file"
+		}
+	}
+
+	if link.Fragment == "" || link.Fragment == "top" {
+		return ""
+	}
+	page, ok := pages[target]
+	if !ok {
+		return ""
+	}
+	if !page.ids[link.Fragment] {
+		return fmt.Sprintf("no element with the id `%v`", link.Fragment)
+	}
+	return ""
+}
+
+// checkExternalLinks requests every link once, a few at a time,
+// and returns why each of them is broken, an empty message for the
+// ones that responded fine
+func checkExternalLinks(links map[string][]string) map[string]string {
+	client := &http.Client{Timeout: 10 * time.Second}
+	results := map[string]string{}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	queue := make(chan string)
+
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for link := range queue {
+				message := checkExternalLink(client, link)
+				mu.Lock()
+				results[link] = message
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for link := range links {
+		queue <- link
+	}
+	close(queue)
+	wg.Wait()
+
+	return results
+}
+
+func checkExternalLink(client *http.Client, link string) string {
+	res, err := client.Head(link)
+	// not every server handles HEAD requests
+	if err == nil && (res.StatusCode == http.StatusMethodNotAllowed || res.StatusCode == http.StatusForbidden) {
+		res.Body.Close()
+		res, err = client.Get(link)
+	}
+	if err != nil {
+		// the url is already part of the report
+		if urlErr, ok := err.(*url.Error); ok {
+			return urlErr.Err.Error()
+		}
+		return err.Error()
+	}
+	res.Body.Close()
+	if res.StatusCode >= 400 {
+		return res.Status
+	}
+	return ""
+}
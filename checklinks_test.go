@@ -0,0 +1,164 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckInternalLink(t *testing.T) {
+	files := map[string]bool{
+		"/index.html":        true,
+		"/guides/index.html": true,
+		"/styles.css":        true,
+		"/404.html":          true,
+	}
+	pages := map[string]*builtPage{
+		"/index.html":        {ids: map[string]bool{"intro": true}},
+		"/guides/index.html": {ids: map[string]bool{"setup": true}},
+	}
+
+	tests := []struct {
+		name     string
+		page     string
+		link     string
+		basePath string
+		want     string
+	}{
+		{"pretty url", "/index.html", "/guides/", "/", ""},
+		{"without the slash", "/index.html", "/guides", "/", ""},
+		{"relative", "/guides/index.html", "../styles.css", "/", ""},
+		{"relative directory", "/guides/index.html", "../", "/", ""},
+		{"missing file", "/index.html", "/blog/", "/", "no such file"},
+		{"anchor on the page", "/guides/index.html", "#setup", "/", ""},
+		{"missing anchor", "/index.html", "#setup", "/", "no element with the id `setup`"},
+		{"anchor on another page", "/index.html", "/guides/#setup", "/", ""},
+		{"top", "/index.html", "#top", "/", ""},
+		{"anchor in a file that isn't a page", "/index.html", "/styles.css#x", "/", ""},
+		{"baseurl", "/index.html", "/docs/guides/", "/docs/", ""},
+		{"baseurl without the slash", "/index.html", "/docs", "/docs/", ""},
+		{"outside of the baseurl", "/index.html", "/guides/", "/docs/", "outside of the baseurl ``"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link, err := url.Parse(tt.link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := checkInternalLink(tt.page, link, tt.basePath, files, pages); got != tt.want {
+				t.Errorf("checkInternalLink(%q, %q) = %q, want %q", tt.page, tt.link, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBuiltPage(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "index.html")
+	content := `<html><head>
+<link rel="preconnect" href="https://fonts.example.com">
+<link rel="stylesheet" href="/styles.css">
+<script src="/app.js"></script>
+</head><body>
+<h2 id="intro">Intro</h2><a name="old"></a>
+<a href="/guides/#setup">setup</a><a href="">empty</a>
+<img src="cat.png"/><video src="a.mp4" poster="a.jpg"></video>
+</body></html>`
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	page, err := parseBuiltPage(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantLinks := []string{"/styles.css", "/app.js", "/guides/#setup", "cat.png", "a.mp4", "a.jpg"}
+	if !reflect.DeepEqual(page.links, wantLinks) {
+		t.Errorf("links = %q, want %q", page.links, wantLinks)
+	}
+	wantIDs := map[string]bool{"intro": true, "old": true}
+	if !reflect.DeepEqual(page.ids, wantIDs) {
+		t.Errorf("ids = %v, want %v", page.ids, wantIDs)
+	}
+}
+
+func TestRunCheckLinks(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "no broken links",
+			files: map[string]string{
+				"index.html":       `<a href="/about/">about</a><a href="mailto:me@x.dev">mail</a><a href="https://x.dev">x</a>`,
+				"about/index.html": `<a href="../#top">home</a>`,
+			},
+			want: 0,
+		},
+		{
+			name: "broken link",
+			files: map[string]string{
+				"index.html": `<a href="/about/">about</a>`,
+			},
+			want: 1,
+		},
+		{
+			name: "broken anchor",
+			files: map[string]string{
+				"index.html": `<a href="#missing">about</a>`,
+			},
+			want: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := t.TempDir()
+			for name, content := range tt.files {
+				filePath := filepath.Join(out, name)
+				if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := runCheckLinks(out, false); got != tt.want {
+				t.Errorf("runCheckLinks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckExternalLinks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/head-not-allowed":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+			}
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	links := map[string][]string{
+		server.URL + "/":                 {"/index.html"},
+		server.URL + "/head-not-allowed": {"/index.html"},
+		server.URL + "/missing":          {"/index.html"},
+	}
+	want := map[string]string{
+		server.URL + "/":                 "",
+		server.URL + "/head-not-allowed": "",
+		server.URL + "/missing":          "404 Not Found",
+	}
+	if got := checkExternalLinks(links); !reflect.DeepEqual(got, want) {
+		t.Errorf("checkExternalLinks() = %v, want %v", got, want)
+	}
+}
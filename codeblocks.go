@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// chromaLinePattern matches the element chroma opens every line of
+// the highlighted code with, for both classes and inline styles
+var chromaLinePattern = regexp.MustCompile(`<span (class="line|style="display:flex;)`)
+
+// CodeBlockConfig is the `code_blocks` key of the markdown config
+type CodeBlockConfig struct {
+	// adds an empty button to the header of every code block
+	// for a script to turn into a copy button
+	CopyButton bool `json:"copy_button"`
+}
+
+// codeBlockAttributes reads the attributes from the info string of
+// fenced code blocks, both `go title="main.go"` and the braced
+// `go {linenos=true}` are supported
+type codeBlockAttributes struct{}
+
+func (cba *codeBlockAttributes) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		block, ok := n.(*ast.FencedCodeBlock)
+		if !ok || !entering || block.Info == nil {
+			return ast.WalkContinue, nil
+		}
+
+		info := block.Info.Segment.Value(source)
+		// a fence can have the attributes without a language
+		noLanguage := bytes.HasPrefix(info, []byte("{"))
+		rest := info
+		if !noLanguage {
+			rest = bytes.TrimSpace(info[len(block.Language(source)):])
+		}
+		if len(rest) == 0 {
+			return ast.WalkContinue, nil
+		}
+
+		attrs := rest
+		if start := bytes.IndexByte(rest, '{'); start != -1 {
+			end := bytes.LastIndexByte(rest, '}')
+			if end < start {
+				return ast.WalkContinue, nil
+			}
+			attrs = append(append(append([]byte{}, rest[:start]...), ' '), rest[start+1:end]...)
+		}
+
+		parsed, ok := parser.ParseAttributes(text.NewReader(append(append([]byte{'{'}, attrs...), '}')))
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+		for _, attr := range parsed {
+			block.SetAttribute(attr.Name, attr.Value)
+		}
+		if noLanguage {
+			block.Info = nil
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// codeBlockRenderer wraps fenced code blocks that have a `title`,
+// `diff=true` or line numbers in the same markup, with a header for
+// the title and the copy button. Code blocks with `diff=true` have their
+// lines starting with `+` and `-` marked as added and removed. The code
+// itself is rendered by the highlighter when there's one
+type codeBlockRenderer struct {
+	config    CodeBlockConfig
+	highlight renderer.NodeRendererFunc
+}
+
+// newCodeBlockRenderer creates the renderer, the highlighter's
+// fenced code block function is used for the code if it's passed
+func newCodeBlockRenderer(config CodeBlockConfig, highlighter renderer.NodeRenderer) renderer.NodeRenderer {
+	r := &codeBlockRenderer{
+		config: config,
+	}
+	if highlighter != nil {
+		highlighter.RegisterFuncs(nodeRendererFuncCapture(func(kind ast.NodeKind, fn renderer.NodeRendererFunc) {
+			if kind == ast.KindFencedCodeBlock {
+				r.highlight = fn
+			}
+		}))
+	}
+	return r
+}
+
+// nodeRendererFuncCapture gets the functions a node renderer
+// registers without adding them to a renderer
+type nodeRendererFuncCapture func(kind ast.NodeKind, fn renderer.NodeRendererFunc)
+
+func (c nodeRendererFuncCapture) Register(kind ast.NodeKind, fn renderer.NodeRendererFunc) {
+	c(kind, fn)
+}
+
+func (r *codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindFencedCodeBlock, r.renderCodeBlock)
+}
+
+func (r *codeBlockRenderer) renderCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+	language := n.Language(source)
+
+	markers := []byte{}
+	diff, _ := n.AttributeString("diff")
+	isDiff := diff == true
+	if isDiff {
+		markers = stripDiffMarkers(n, source)
+	}
+
+	title, hasTitle := n.AttributeString("title")
+	_, hasLineNumbers := n.AttributeString("linenos")
+	// blocks without any of the features are left as they are
+	wrapped := isDiff || hasTitle || hasLineNumbers || r.config.CopyButton
+
+	if wrapped {
+		_, _ = w.WriteString(`<div class="code-block"`)
+		if len(language) > 0 {
+			_, _ = w.WriteString(` data-lang="`)
+			_, _ = w.Write(util.EscapeHTML(language))
+			_ = w.WriteByte('"')
+		}
+		_, _ = w.WriteString(">\n")
+	}
+
+	if hasTitle || r.config.CopyButton {
+		_, _ = w.WriteString(`<div class="code-block-header">`)
+		if hasTitle {
+			_, _ = w.WriteString(`<span class="code-block-title">`)
+			_, _ = w.Write(util.EscapeHTML([]byte(fmt.Sprint(attributeValue(title)))))
+			_, _ = w.WriteString(`</span>`)
+		}
+		if r.config.CopyButton {
+			_, _ = w.WriteString(`<button class="code-block-copy" type="button" aria-label="Copy code"></button>`)
+		}
+		_, _ = w.WriteString("</div>\n")
+	}
+
+	highlighted := false
+	if r.highlight != nil {
+		var buf bytes.Buffer
+		bufWriter := bufio.NewWriter(&buf)
+		if _, err := r.highlight(bufWriter, source, n, true); err != nil {
+			return ast.WalkStop, err
+		}
+		if _, err := r.highlight(bufWriter, source, n, false); err != nil {
+			return ast.WalkStop, err
+		}
+		_ = bufWriter.Flush()
+
+		// the highlighter leaves the code as it is for languages it
+		// doesn't know, without the lines to put the diff classes on
+		if len(markers) == 0 || chromaLinePattern.Match(buf.Bytes()) {
+			highlighted = true
+			code := markDiffLines(buf.Bytes(), markers)
+			_, _ = w.Write(code)
+			if !bytes.HasSuffix(code, []byte("\n")) {
+				_ = w.WriteByte('\n')
+			}
+		}
+	}
+	if !highlighted {
+		writeCodeLines(w, source, n, language, markers)
+	}
+
+	if wrapped {
+		_, _ = w.WriteString("</div>\n")
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// writeCodeLines writes the code block without highlighting,
+// the lines with diff markers are wrapped to add their class
+func writeCodeLines(w util.BufWriter, source []byte, n *ast.FencedCodeBlock, language []byte, markers []byte) {
+	_, _ = w.WriteString("<pre><code")
+	if len(language) > 0 {
+		_, _ = w.WriteString(` class="language-`)
+		_, _ = w.Write(util.EscapeHTML(language))
+		_ = w.WriteByte('"')
+	}
+	_ = w.WriteByte('>')
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		class := diffLineClass(markers, i)
+		if class != "" {
+			_, _ = w.WriteString(`<span class="line ` + class + `">`)
+		}
+		_, _ = w.Write(util.EscapeHTML(line.Value(source)))
+		if class != "" {
+			_, _ = w.WriteString("</span>")
+		}
+	}
+	_, _ = w.WriteString("</code></pre>\n")
+}
+
+// stripDiffMarkers drops the `+`, `-` or ` ` at the start of every
+// line of the code block and returns them in order
+func stripDiffMarkers(n *ast.FencedCodeBlock, source []byte) []byte {
+	markers := make([]byte, n.Lines().Len())
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		value := line.Value(source)
+		if len(value) == 0 || line.Padding > 0 {
+			continue
+		}
+		switch value[0] {
+		case '+', '-', ' ':
+			markers[i] = value[0]
+			n.Lines().Set(i, line.WithStart(line.Start+1))
+		}
+	}
+	return markers
+}
+
+func diffLineClass(markers []byte, index int) string {
+	if index >= len(markers) {
+		return ""
+	}
+	switch markers[index] {
+	case '+':
+		return "diff-add"
+	case '-':
+		return "diff-remove"
+	}
+	return ""
+}
+
+// markDiffLines adds the diff classes to the lines of highlighted code
+func markDiffLines(highlighted []byte, markers []byte) []byte {
+	if len(markers) == 0 {
+		return highlighted
+	}
+	index := -1
+	return chromaLinePattern.ReplaceAllFunc(highlighted, func(match []byte) []byte {
+		index++
+		class := diffLineClass(markers, index)
+		if class == "" {
+			return match
+		}
+		if bytes.HasPrefix(match, []byte(`<span class="line`)) {
+			return []byte(`<span class="line ` + class)
+		}
+		return []byte(`<span class="` + class + `" style="display:flex;`)
+	})
+}
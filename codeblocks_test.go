@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+func TestCodeBlocks(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		highlight bool
+		config    CodeBlockConfig
+		want      string
+		// only the start is compared, the rest is left to chroma
+		prefix bool
+	}{
+		{
+			name:    "no attributes",
+			content: "```go\nx := 1\n```",
+			want:    "<pre><code class=\"language-go\">x := 1\n</code></pre>\n",
+		},
+		{
+			name:    "no language",
+			content: "```\n<b>\n```",
+			want:    "<pre><code>&lt;b&gt;\n</code></pre>\n",
+		},
+		{
+			name:    "title",
+			content: "```go title=\"main.go\"\nx\n```",
+			want: "<div class=\"code-block\" data-lang=\"go\">\n" +
+				"<div class=\"code-block-header\"><span class=\"code-block-title\">main.go</span></div>\n" +
+				"<pre><code class=\"language-go\">x\n</code></pre>\n</div>\n",
+		},
+		{
+			name:    "braced attributes",
+			content: "```go {title=\"main.go\"}\nx\n```",
+			want: "<div class=\"code-block\" data-lang=\"go\">\n" +
+				"<div class=\"code-block-header\"><span class=\"code-block-title\">main.go</span></div>\n" +
+				"<pre><code class=\"language-go\">x\n</code></pre>\n</div>\n",
+		},
+		{
+			name:    "copy button",
+			content: "```go\nx\n```",
+			config:  CodeBlockConfig{CopyButton: true},
+			want: "<div class=\"code-block\" data-lang=\"go\">\n" +
+				"<div class=\"code-block-header\"><button class=\"code-block-copy\" type=\"button\" aria-label=\"Copy code\"></button></div>\n" +
+				"<pre><code class=\"language-go\">x\n</code></pre>\n</div>\n",
+		},
+		{
+			name:    "diff",
+			content: "```go diff=true\n a\n-b\n+c\n```",
+			want: "<div class=\"code-block\" data-lang=\"go\">\n" +
+				"<pre><code class=\"language-go\">a\n<span class=\"line diff-remove\">b\n</span><span class=\"line diff-add\">c\n</span></code></pre>\n</div>\n",
+		},
+		{
+			name:    "attributes without a language",
+			content: "``` {diff=true}\n-a\n+b\n```",
+			want: "<div class=\"code-block\">\n" +
+				"<pre><code><span class=\"line diff-remove\">a\n</span><span class=\"line diff-add\">b\n</span></code></pre>\n</div>\n",
+		},
+		{
+			name:      "highlighted",
+			content:   "```go\nx\n```",
+			highlight: true,
+			want:      "<pre tabindex=\"0\" class=\"chroma\"><code><span class=\"line\"><span class=\"cl\"><span class=\"nx\">x</span>\n</span></span></code></pre>\n",
+		},
+		{
+			name:      "highlighted diff",
+			content:   "```go diff=true\n-a\n+b\n```",
+			highlight: true,
+			want: "<div class=\"code-block\" data-lang=\"go\">\n<pre tabindex=\"0\" class=\"chroma\"><code>" +
+				"<span class=\"line diff-remove\"><span class=\"cl\"><span class=\"nx\">a</span>\n</span></span>" +
+				"<span class=\"line diff-add\"><span class=\"cl\"><span class=\"nx\">b</span>\n</span></span>" +
+				"</code></pre>\n</div>\n",
+		},
+		{
+			name:      "highlighted diff in an unknown language",
+			content:   "```go-diff diff=true\n-a\n+b\n```",
+			highlight: true,
+			want: "<div class=\"code-block\" data-lang=\"go-diff\">\n" +
+				"<pre><code class=\"language-go-diff\"><span class=\"line diff-remove\">a\n</span><span class=\"line diff-add\">b\n</span></code></pre>\n</div>\n",
+		},
+		{
+			name:      "highlighted diff without a language",
+			content:   "``` {diff=true}\n-a\n+b\n```",
+			highlight: true,
+			want: "<div class=\"code-block\">\n" +
+				"<pre><code><span class=\"line diff-remove\">a\n</span><span class=\"line diff-add\">b\n</span></code></pre>\n</div>\n",
+		},
+		{
+			name:      "line numbers",
+			content:   "```go {linenos=true}\nx\n```",
+			highlight: true,
+			want:      "<div class=\"code-block\" data-lang=\"go\">\n<pre tabindex=\"0\" class=\"chroma\">",
+			prefix:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var highlighter renderer.NodeRenderer
+			if tt.highlight {
+				highlighter = newHighlightRenderer(HighlightConfig{Theme: "bw", Classes: true})
+			}
+			md := goldmark.New(
+				goldmark.WithParserOptions(parser.WithASTTransformers(
+					util.Prioritized(&codeBlockAttributes{}, 100),
+				)),
+				goldmark.WithRendererOptions(renderer.WithNodeRenderers(
+					util.Prioritized(newCodeBlockRenderer(tt.config, highlighter), 150),
+				)),
+			)
+
+			var got bytes.Buffer
+			if err := md.Convert([]byte(tt.content), &got); err != nil {
+				t.Fatal(err)
+			}
+			matches := got.String() == tt.want
+			if tt.prefix {
+				matches = strings.HasPrefix(got.String(), tt.want)
+			}
+			if !matches {
+				t.Errorf("Convert(%q) = %q, want %q", tt.content, got.String(), tt.want)
+			}
+		})
+	}
+}
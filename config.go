@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configFileNames are looked up in the base path
+// when no config file is passed with `-config`
+var configFileNames = []string{"alvu.yml", "alvu.yaml", "alvu.toml", "alvu.json"}
+
+// SiteConfig is the optional config file of the site
+type SiteConfig struct {
+	Menus    map[string][]*MenuEntry `json:"menus"`
+	TOC      TOCConfig               `json:"toc"`
+	Summary  SummaryConfig           `json:"summary"`
+	Markdown MarkdownConfig          `json:"markdown"`
+	// pages created from the data files
+	Generators []*Generator `json:"generators"`
+}
+
+// findConfig returns the config file passed with `-config` or the
+// first of the config files in the base path, empty if there's none
+func findConfig(basePath string, configPath string) string {
+	if configPath != "" {
+		return configPath
+	}
+	for _, name := range configFileNames {
+		candidate := filepath.Join(basePath, name)
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// LoadConfig reads the site config, the defaults
+// are returned if there's no config file
+func LoadConfig(basePath string, configPath string) (*SiteConfig, error) {
+	config := &SiteConfig{
+		TOC: TOCConfig{
+			StartLevel: 2,
+			EndLevel:   3,
+		},
+		Summary: SummaryConfig{
+			Words: 70,
+		},
+	}
+
+	configPath = findConfig(basePath, configPath)
+	if configPath == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config, error: %v", err)
+	}
+
+	format := strings.TrimPrefix(filepath.Ext(configPath), ".")
+	if format == "yml" {
+		format = "yaml"
+	}
+
+	var values map[string]interface{}
+	if line, err := unmarshalData(format, data, &values); err != nil {
+		return nil, fmt.Errorf("%v:%v: invalid config, %v", configPath, max(line, 1), err)
+	}
+
+	// the decoders don't share struct tags, so everything
+	// is normalized through json
+	normalized, err := json.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("%v: invalid config, %v", configPath, err)
+	}
+	if err := json.Unmarshal(normalized, config); err != nil {
+		return nil, fmt.Errorf("%v: invalid config, %v", configPath, err)
+	}
+
+	return config, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// dataFormats are the extensions of the files loaded
+// from the data directory and their format
+var dataFormats = map[string]string{
+	".yml":  "yaml",
+	".yaml": "yaml",
+	".json": "json",
+	".toml": "toml",
+	".csv":  "csv",
+}
+
+// CollectData loads the files in the data directory into a map
+// keyed by their path without the extension, `data/team/members.yml`
+// is `team.members`. Rows of csv files are keyed by the header row
+func CollectData(dataPath string) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+
+	err := filepath.WalkDir(dataPath, func(filePath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		value, err := readDataFile(filePath)
+		if err != nil || value == nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dataPath, filePath)
+		if err != nil {
+			return err
+		}
+		keys := strings.Split(filepath.ToSlash(strings.TrimSuffix(rel, filepath.Ext(rel))), "/")
+
+		parent := data
+		for i, key := range keys[:len(keys)-1] {
+			child, ok := parent[key].(map[string]interface{})
+			if !ok {
+				if _, exists := parent[key]; exists {
+					return fmt.Errorf("%v: `%v` is already a data file", filePath, strings.Join(keys[:i+1], "."))
+				}
+				child = map[string]interface{}{}
+				parent[key] = child
+			}
+			parent = child
+		}
+		key := keys[len(keys)-1]
+		if _, exists := parent[key]; exists {
+			return fmt.Errorf("%v: `%v` is already defined by another data file or directory", filePath, strings.Join(keys, "."))
+		}
+		parent[key] = value
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// parseDataFile decodes the content of a data file, errors
+// start with the line they are at
+func parseDataFile(format string, content []byte) (interface{}, error) {
+	if format == "csv" {
+		records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+		if err != nil {
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				return nil, fmt.Errorf("%v: %v", parseErr.Line, parseErr.Err)
+			}
+			return nil, fmt.Errorf("1: %v", err)
+		}
+		rows := []interface{}{}
+		if len(records) == 0 {
+			return rows, nil
+		}
+		header := records[0]
+		for _, record := range records[1:] {
+			row := map[string]interface{}{}
+			for i, column := range header {
+				if i < len(record) {
+					row[column] = record[i]
+				}
+			}
+			rows = append(rows, row)
+		}
+		return rows, nil
+	}
+
+	if format == "toml" {
+		// toml documents are always a table
+		var table map[string]interface{}
+		line, err := unmarshalData(format, content, &table)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", max(line, 1), err)
+		}
+		return table, nil
+	}
+	var value interface{}
+	line, err := unmarshalData(format, content, &value)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %v", max(line, 1), err)
+	}
+	return value, nil
+}
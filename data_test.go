@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates the files with their directories under dir
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		filePath := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseDataFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		content string
+		want    interface{}
+		wantErr string
+	}{
+		{"yaml list", "yaml", "- a\n- b", []interface{}{"a", "b"}, ""},
+		{"json", "json", `{"a": [1]}`, map[string]interface{}{"a": []interface{}{float64(1)}}, ""},
+		{"toml", "toml", "a = \"b\"", map[string]interface{}{"a": "b"}, ""},
+		{
+			name:    "csv",
+			format:  "csv",
+			content: "name,role\nann,dev\nbob,ops",
+			want: []interface{}{
+				map[string]interface{}{"name": "ann", "role": "dev"},
+				map[string]interface{}{"name": "bob", "role": "ops"},
+			},
+		},
+		{"empty csv", "csv", "", []interface{}{}, ""},
+		{"invalid yaml", "yaml", "a: 1\nb: [\n", nil, "2: "},
+		{"invalid csv", "csv", "a,b\n1,2,3", nil, "2: wrong number of fields"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDataFile(tt.format, []byte(tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("parseDataFile() error = %v, want it to start with %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDataFile() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectData(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		want    map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "nested directories",
+			files: map[string]string{
+				"site.yml":          "name: alvu",
+				"team/members.json": `["ann"]`,
+				"team/notes.txt":    "not data",
+			},
+			want: map[string]interface{}{
+				"site": map[string]interface{}{"name": "alvu"},
+				"team": map[string]interface{}{"members": []interface{}{"ann"}},
+			},
+		},
+		{
+			name:    "same key from two files",
+			files:   map[string]string{"site.yml": "a: 1", "site.json": `{"a": 2}`},
+			wantErr: "`site` is already defined",
+		},
+		{
+			name:    "file and directory",
+			files:   map[string]string{"team.yml": "a: 1", "team/members.yml": "- ann"},
+			wantErr: "`team` is already",
+		},
+		{
+			name:    "invalid file",
+			files:   map[string]string{"site.yml": "a: [\n"},
+			wantErr: "site.yml:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dataPath := t.TempDir()
+			writeFiles(t, dataPath, tt.files)
+			got, err := CollectData(dataPath)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("CollectData() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CollectData() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	got, err := CollectData(filepath.Join(t.TempDir(), "missing"))
+	if err != nil || len(got) != 0 {
+		t.Errorf("CollectData() without the directory = %v, %v, want an empty map", got, err)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sectionIndexName marks a directory as a section, its frontmatter
+// can `cascade` values down to every page nested in the directory
+const sectionIndexName = "_index.md"
+
+// defaultsFileNames are the files that hold default meta for
+// every page in the directory they are placed in
+var defaultsFileNames = []string{"_defaults.yaml", "_defaults.yml", "_defaults.toml", "_defaults.json"}
+
+// DirDefaults holds the defaults declared for a single directory
+type DirDefaults struct {
+	// from the _defaults file, applies to all files in the directory
+	file map[string]interface{}
+	// from the _index.md `cascade` key, applies to everything
+	// but the _index.md itself
+	cascade map[string]interface{}
+}
+
+// DefaultsCollection maps directories to the defaults declared in them
+type DefaultsCollection map[string]*DirDefaults
+
+// CollectDefaults reads the defaults files from the pages directory and
+// the `cascade` values from the already loaded section index files
+func CollectDefaults(pagesPath string, files []*AlvuFile) (DefaultsCollection, error) {
+	defaults := DefaultsCollection{}
+
+	err := filepath.WalkDir(pagesPath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !Contains(defaultsFileNames, d.Name()) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		format := strings.TrimPrefix(filepath.Ext(path), ".")
+		if format == "yml" {
+			format = "yaml"
+		}
+
+		var values map[string]interface{}
+		if line, err := unmarshalData(format, data, &values); err != nil {
+			return fmt.Errorf("%v:%v: invalid defaults, %v", path, max(line, 1), err)
+		}
+
+		defaults.dir(filepath.Dir(path)).file = mergeMapWithCheck(defaults.dir(filepath.Dir(path)).file, values)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, af := range files {
+		if filepath.Base(af.sourcePath) != sectionIndexName {
+			continue
+		}
+		cascade, ok := af.meta["cascade"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		defaults.dir(filepath.Dir(af.sourcePath)).cascade = cascade
+	}
+
+	return defaults, nil
+}
+
+func (dc DefaultsCollection) dir(dirPath string) *DirDefaults {
+	if _, ok := dc[dirPath]; !ok {
+		dc[dirPath] = &DirDefaults{}
+	}
+	return dc[dirPath]
+}
+
+// Apply merges the defaults of every directory above the
+// file into its meta, values closer to the file win and the
+// file's own meta wins over all of them
+func (dc DefaultsCollection) Apply(pagesPath string, af *AlvuFile) {
+	dirs := []string{}
+	dir := filepath.Dir(af.sourcePath)
+	for {
+		dirs = append([]string{dir}, dirs...)
+		if dir == pagesPath || !strings.HasPrefix(dir, pagesPath) {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	isSectionIndex := filepath.Base(af.sourcePath) == sectionIndexName
+	toMerge := []any{}
+	for index, dirPath := range dirs {
+		dirDefaults, ok := dc[dirPath]
+		if !ok {
+			continue
+		}
+		toMerge = append(toMerge, dirDefaults.file)
+		if isSectionIndex && index == len(dirs)-1 {
+			continue
+		}
+		toMerge = append(toMerge, dirDefaults.cascade)
+	}
+
+	if len(toMerge) == 0 {
+		return
+	}
+
+	toMerge = append(toMerge, af.meta)
+	af.meta = mergeMapWithCheck(toMerge...)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestApplyDefaults(t *testing.T) {
+	pagesPath := filepath.Join(t.TempDir(), "pages")
+	files := map[string]string{
+		"_defaults.yml":             "layout: base\nauthor: site",
+		"_index.md":                 "---\ncascade:\n  author: root\n  draft: false\n---\n",
+		"blog/_defaults.toml":       "layout = \"post\"",
+		"blog/_index.md":            "---\ntitle: Blog\ncascade:\n  author: blog\n---\n",
+		"blog/post.md":              "---\ntitle: Post\n---\n",
+		"blog/own.md":               "---\nauthor: me\ndraft: true\n---\n",
+		"blog/2024/old.md":          "",
+		"blog/2024/_defaults.json":  `{"archived": true}`,
+		"docs/intro.md":             "",
+		"docs/nested/_defaults.yml": "layout: docs",
+	}
+	for name, content := range files {
+		path := filepath.Join(pagesPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		file string
+		want map[string]interface{}
+	}{
+		{
+			name: "root section doesn't get its own cascade",
+			file: "_index.md",
+			want: map[string]interface{}{
+				"layout":  "base",
+				"author":  "site",
+				"cascade": map[string]interface{}{"author": "root", "draft": false},
+			},
+		},
+		{
+			name: "section gets the cascade from above",
+			file: "blog/_index.md",
+			want: map[string]interface{}{
+				"layout":  "post",
+				"author":  "root",
+				"draft":   false,
+				"title":   "Blog",
+				"cascade": map[string]interface{}{"author": "blog"},
+			},
+		},
+		{
+			name: "closest values win",
+			file: "blog/post.md",
+			want: map[string]interface{}{"layout": "post", "author": "blog", "draft": false, "title": "Post"},
+		},
+		{
+			name: "frontmatter wins",
+			file: "blog/own.md",
+			want: map[string]interface{}{"layout": "post", "author": "me", "draft": true},
+		},
+		{
+			name: "nested directory without a section",
+			file: "blog/2024/old.md",
+			want: map[string]interface{}{"layout": "post", "author": "blog", "draft": false, "archived": true},
+		},
+		{
+			name: "defaults of nested directories don't apply",
+			file: "docs/intro.md",
+			want: map[string]interface{}{"layout": "base", "author": "root", "draft": false},
+		},
+	}
+
+	al := &Alvu{pagesPath: pagesPath}
+	byName := map[string]*AlvuFile{}
+	for name := range files {
+		if filepath.Ext(name) != ".md" {
+			continue
+		}
+		af := al.NewFile(filepath.Join(pagesPath, name), name)
+		if err := af.Load(); err != nil {
+			t.Fatal(err)
+		}
+		al.AddFile(af)
+		byName[name] = af
+	}
+	if err := al.ApplyDefaults(al.files...); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := byName[tt.file].meta; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("meta of %v = %v, want %v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectDefaultsInvalid(t *testing.T) {
+	pagesPath := t.TempDir()
+	if err := os.WriteFile(filepath.Join(pagesPath, "_defaults.yml"), []byte("a: 1\nb: [\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CollectDefaults(pagesPath, nil); err == nil {
+		t.Error("CollectDefaults() with invalid yaml didn't return an error")
+	}
+}
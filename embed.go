@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// embedShortcode is the built in `embed` shortcode, it puts the
+// markdown of another file into the page without its frontmatter
+//
+//	{{% embed "/partials/install.md" shift=1 %}}
+//
+// The embedded content goes through the same template pass and
+// shortcodes as the page, `shift` moves its headings down a level
+func embedShortcode(data *ShortcodeData) (string, error) {
+	se := data.expansion
+	name := data.Get("file")
+	if name == "" {
+		name = data.Get(0)
+	}
+	if name == "" {
+		return "", fmt.Errorf("missing the file to embed")
+	}
+
+	shift := 0
+	if value := data.Get("shift"); value != "" {
+		var err error
+		if shift, err = strconv.Atoi(value); err != nil {
+			return "", fmt.Errorf("invalid shift `%v`", value)
+		}
+	}
+
+	filePath := se.file.resolveFile(name)
+	for _, embedded := range se.embeds {
+		if filepath.Clean(embedded) == filepath.Clean(filePath) {
+			return "", fmt.Errorf("embed cycle %v -> %v", strings.Join(se.embeds, " -> "), filePath)
+		}
+	}
+	se.page.includes = append(se.page.includes, filePath)
+
+	embedded := &AlvuFile{
+		lock:       &sync.Mutex{},
+		sourcePath: filePath,
+		name:       filepath.Base(filePath),
+		alvu:       se.page.alvu,
+	}
+	if err := embedded.Load(); err != nil {
+		return "", err
+	}
+
+	content := embedded.writeableContent
+	if embedded.isMarkdown() && shift != 0 {
+		content = shiftHeadings(content, shift)
+	}
+	if !embedded.isTemplated() {
+		return string(se.verbatim.stash(content)), nil
+	}
+
+	// the html placeholders are shared with the
+	// page so they are restored along with it
+	nested := &shortcodeExpansion{
+		shortcodes: se.shortcodes,
+		page:       se.page,
+		file:       embedded,
+		data:       se.data,
+		verbatim:   se.verbatim,
+		html:       se.html,
+		embeds:     append(append([]string{}, se.embeds...), filePath),
+	}
+	expanded, err := nested.expandContent(content)
+	se.html = nested.html
+	if err != nil {
+		return "", err
+	}
+
+	// keep the content in its own blocks
+	return "\n" + strings.TrimSuffix(string(expanded), "\n") + "\n\n", nil
+}
+
+// shiftHeadings moves the `#` headings of the markdown by the shift,
+// staying between levels 1 and 6, headings in fences are left as is
+func shiftHeadings(content []byte, shift int) []byte {
+	skip := fencedCodeRanges(content)
+	var buf bytes.Buffer
+	offset := 0
+
+	for offset < len(content) {
+		end := bytes.IndexByte(content[offset:], '\n')
+		if end == -1 {
+			end = len(content)
+		} else {
+			end += offset + 1
+		}
+		line := content[offset:end]
+
+		skipped := false
+		for _, r := range skip {
+			if offset >= r[0] && offset < r[1] {
+				skipped = true
+				break
+			}
+		}
+
+		trimmed := bytes.TrimLeft(line, " ")
+		level := len(trimmed) - len(bytes.TrimLeft(trimmed, "#"))
+		if skipped || len(line)-len(trimmed) > 3 || level == 0 || level > 6 ||
+			(len(trimmed) > level && !isHeadingSeparator(trimmed[level])) {
+			buf.Write(line)
+			offset = end
+			continue
+		}
+
+		newLevel := min(max(level+shift, 1), 6)
+		buf.Write(line[:len(line)-len(trimmed)])
+		buf.WriteString(strings.Repeat("#", newLevel))
+		buf.Write(trimmed[level:])
+		offset = end
+	}
+
+	return buf.Bytes()
+}
+
+// isHeadingSeparator is true for what can come after the `#`s of a
+// heading, `\r` is there for files with CRLF line endings
+func isHeadingSeparator(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestShiftHeadings(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		shift   int
+		want    string
+	}{
+		{"down", "# One\n## Two\ntext\n", 1, "## One\n### Two\ntext\n"},
+		{"up", "### One\n", -1, "## One\n"},
+		{"kept within the levels", "# One\n###### Six\n", -2, "# One\n#### Six\n"},
+		{"capped at six", "##### Five\n", 3, "###### Five\n"},
+		{"empty heading", "#\n##", 1, "##\n###"},
+		{"crlf line endings", "#\r\n# One\r\n", 1, "##\r\n## One\r\n"},
+		{"indented", "   # One\n    # Code\n", 1, "   ## One\n    # Code\n"},
+		{"not a heading", "#hashtag\n####### seven\n", 1, "#hashtag\n####### seven\n"},
+		{"fenced code", "```\n# comment\n```\n# One\n", 1, "```\n# comment\n```\n## One\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(shiftHeadings([]byte(tt.content), tt.shift)); got != tt.want {
+				t.Errorf("shiftHeadings(%q, %v) = %q, want %q", tt.content, tt.shift, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmbedShortcode(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"note.md":    "---\ntitle: Note\n---\n# Note\n\nsome text",
+		"nested.md":  "before {{% embed \"note.md\" shift=1 %}}",
+		"raw.md":     "---\nraw: true\n---\n{{ .Page.Title }}",
+		"self.md":    "{{% embed \"self.md\" %}}",
+		"cycle-a.md": "{{% embed \"cycle-b.md\" %}}",
+		"cycle-b.md": "{{% embed \"cycle-a.md\" %}}",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "frontmatter is left out",
+			content: "{{% embed \"note.md\" %}}",
+			want:    "\n# Note\n\nsome text\n\n",
+		},
+		{
+			name:    "shift",
+			content: "{{% embed file=\"note.md\" shift=2 %}}",
+			want:    "\n### Note\n\nsome text\n\n",
+		},
+		{
+			name:    "nested",
+			content: "{{% embed \"nested.md\" %}}",
+			want:    "\nbefore \n## Note\n\nsome text\n\n\n",
+		},
+		{
+			name:    "raw files aren't templated",
+			content: "{{% embed \"raw.md\" %}}",
+			want:    "{{ .Page.Title }}",
+		},
+		{
+			name:    "missing file",
+			content: "{{% embed %}}",
+			wantErr: "missing the file to embed",
+		},
+		{
+			name:    "invalid shift",
+			content: "{{% embed \"note.md\" shift=down %}}",
+			wantErr: "invalid shift `down`",
+		},
+		{
+			name:    "embedding itself",
+			content: "{{% embed \"self.md\" %}}",
+			wantErr: "embed cycle",
+		},
+		{
+			name:    "cycle",
+			content: "{{% embed \"cycle-a.md\" %}}",
+			wantErr: "embed cycle",
+		},
+	}
+
+	shortcodes := ShortcodeCollection{"embed": &Shortcode{builtin: embedShortcode, inline: true}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			af := &AlvuFile{sourcePath: filepath.Join(dir, "page.md"), alvu: &Alvu{}}
+			verbatim := &verbatimStore{}
+			_, got, err := shortcodes.Expand(af, []byte(tt.content), PageRenderData{}, verbatim)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expand() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(verbatim.Restore(got)); got != tt.want {
+				t.Errorf("Expand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
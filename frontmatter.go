@@ -0,0 +1,164 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+var yamlErrLinePattern = regexp.MustCompile(`line (\d+)`)
+var yamlErrPrefixPattern = regexp.MustCompile(`^yaml: line \d+: `)
+var tomlErrPrefixPattern = regexp.MustCompile(`^toml: line \d+[^:]*: `)
+
+// FrontmatterError points to the file and line that the
+// frontmatter failed to parse at
+type FrontmatterError struct {
+	File string
+	Line int
+	Err  error
+}
+
+func (fe *FrontmatterError) Error() string {
+	return fmt.Sprintf("%v:%v: invalid frontmatter, %v", fe.File, fe.Line, fe.Err)
+}
+
+func (fe *FrontmatterError) Unwrap() error {
+	return fe.Err
+}
+
+// frontmatter is the result of splitting a file into
+// its metadata block and the remaining body
+type frontmatter struct {
+	format string
+	raw    []byte
+	body   []byte
+	// line of the file the raw block starts on
+	line int
+}
+
+// splitFrontmatter looks for a frontmatter block at the very start of the
+// content, the delimiters have to be on their own line.
+//
+//	---  yaml  ---
+//	+++  toml  +++
+//	{    json    }
+//
+// ok is false if the content doesn't start with a complete block
+func splitFrontmatter(content []byte) (fm frontmatter, ok bool) {
+	content = bytes.TrimPrefix(content, utf8BOM)
+
+	firstLine, rest := cutLine(content)
+	switch string(bytes.TrimRight(firstLine, " \t\r\n")) {
+	case "---":
+		return splitDelimited(rest, "---", "yaml")
+	case "+++":
+		return splitDelimited(rest, "+++", "toml")
+	}
+
+	if bytes.HasPrefix(content, []byte("{")) {
+		dec := json.NewDecoder(bytes.NewReader(content))
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			// a lone `{` can't be anything but broken frontmatter,
+			// pass it all down so the error gets reported
+			if string(bytes.TrimSpace(firstLine)) == "{" {
+				return frontmatter{format: "json", raw: content, line: 1}, true
+			}
+			return fm, false
+		}
+		end := int(dec.InputOffset())
+		// the closing brace has to end the line
+		_, body := cutLine(content[end:])
+		if len(bytes.TrimSpace(content[end:len(content)-len(body)])) != 0 {
+			return fm, false
+		}
+		return frontmatter{
+			format: "json",
+			raw:    content[:end],
+			body:   body,
+			line:   1,
+		}, true
+	}
+
+	return fm, false
+}
+
+func splitDelimited(content []byte, delimiter string, format string) (fm frontmatter, ok bool) {
+	offset := 0
+	for offset < len(content) {
+		line, rest := cutLine(content[offset:])
+		if string(bytes.TrimRight(line, " \t\r\n")) == delimiter {
+			return frontmatter{
+				format: format,
+				raw:    content[:offset],
+				body:   rest,
+				line:   2,
+			}, true
+		}
+		offset += len(line)
+	}
+	return fm, false
+}
+
+// cutLine splits the content after the first newline
+func cutLine(content []byte) (line []byte, rest []byte) {
+	index := bytes.IndexByte(content, '\n')
+	if index == -1 {
+		return content, nil
+	}
+	return content[:index+1], content[index+1:]
+}
+
+// isYAMLMapping is false when the yaml decodes to something
+// other than a map, e.g. a paragraph of text between two
+// horizontal rules
+func isYAMLMapping(data []byte) bool {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return true
+	}
+	if len(node.Content) == 0 {
+		return true
+	}
+	return node.Content[0].Kind == yaml.MappingNode
+}
+
+// unmarshalData decodes yaml, toml or json into v, the returned
+// error carries the line number relative to the start of data when
+// the decoder provides one
+func unmarshalData(format string, data []byte, v any) (line int, err error) {
+	switch format {
+	case "yaml":
+		err = yaml.Unmarshal(data, v)
+		if err != nil {
+			if match := yamlErrLinePattern.FindStringSubmatch(err.Error()); match != nil {
+				line, _ = strconv.Atoi(match[1])
+			}
+			err = errors.New(yamlErrPrefixPattern.ReplaceAllString(err.Error(), ""))
+		}
+	case "toml":
+		err = toml.Unmarshal(data, v)
+		var parseErr toml.ParseError
+		if errors.As(err, &parseErr) {
+			line = parseErr.Position.Line
+			err = errors.New(tomlErrPrefixPattern.ReplaceAllString(parseErr.Error(), ""))
+		}
+	case "json":
+		err = json.Unmarshal(data, v)
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			line = bytes.Count(data[:syntaxErr.Offset], []byte("\n")) + 1
+		}
+	default:
+		err = fmt.Errorf("unsupported format %q", format)
+	}
+	return
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSplitFrontmatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		ok      bool
+		format  string
+		raw     string
+		body    string
+		line    int
+	}{
+		{
+			name:    "yaml",
+			content: "---\ntitle: Hello\n---\n# Body\n",
+			ok:      true,
+			format:  "yaml",
+			raw:     "title: Hello\n",
+			body:    "# Body\n",
+			line:    2,
+		},
+		{
+			name:    "crlf",
+			content: "---\r\ntitle: Hello\r\n---\r\nbody\r\n",
+			ok:      true,
+			format:  "yaml",
+			raw:     "title: Hello\r\n",
+			body:    "body\r\n",
+			line:    2,
+		},
+		{
+			name:    "byte order mark",
+			content: "\xef\xbb\xbf---\ntitle: Hello\n---\nbody",
+			ok:      true,
+			format:  "yaml",
+			raw:     "title: Hello\n",
+			body:    "body",
+			line:    2,
+		},
+		{
+			name:    "delimiters in values",
+			content: "---\ntitle: a --- b\nnote: |\n  ---\n---\nbody",
+			ok:      true,
+			format:  "yaml",
+			raw:     "title: a --- b\nnote: |\n  ---\n",
+			body:    "body",
+			line:    2,
+		},
+		{
+			name:    "trailing whitespace on delimiters",
+			content: "--- \ntitle: Hello\n---\t\nbody",
+			ok:      true,
+			format:  "yaml",
+			raw:     "title: Hello\n",
+			body:    "body",
+			line:    2,
+		},
+		{
+			name:    "empty",
+			content: "---\n---\nbody",
+			ok:      true,
+			format:  "yaml",
+			raw:     "",
+			body:    "body",
+			line:    2,
+		},
+		{
+			name:    "no closing delimiter",
+			content: "---\ntitle: Hello\nbody",
+			ok:      false,
+		},
+		{
+			name:    "delimiter not on the first line",
+			content: "\n---\ntitle: Hello\n---\n",
+			ok:      false,
+		},
+		{
+			name:    "longer rule",
+			content: "----\ntitle: Hello\n----\n",
+			ok:      false,
+		},
+		{
+			name:    "toml",
+			content: "+++\ntitle = \"Hello\"\n+++\nbody",
+			ok:      true,
+			format:  "toml",
+			raw:     "title = \"Hello\"\n",
+			body:    "body",
+			line:    2,
+		},
+		{
+			name:    "json",
+			content: "{\n  \"title\": \"Hello\"\n}\nbody",
+			ok:      true,
+			format:  "json",
+			raw:     "{\n  \"title\": \"Hello\"\n}",
+			body:    "body",
+			line:    1,
+		},
+		{
+			name:    "json with braces in values",
+			content: "{\"title\": \"}\"}\nbody",
+			ok:      true,
+			format:  "json",
+			raw:     "{\"title\": \"}\"}",
+			body:    "body",
+			line:    1,
+		},
+		{
+			name:    "json followed by text on the same line",
+			content: "{\"a\": 1} text\nbody",
+			ok:      false,
+		},
+		{
+			name:    "text starting with a brace",
+			content: "{not json\nbody",
+			ok:      false,
+		},
+		{
+			name:    "broken json",
+			content: "{\n  \"title\": \n",
+			ok:      true,
+			format:  "json",
+			raw:     "{\n  \"title\": \n",
+			line:    1,
+		},
+		{
+			name:    "no frontmatter",
+			content: "# Title\n---\n",
+			ok:      false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, ok := splitFrontmatter([]byte(tt.content))
+			if ok != tt.ok {
+				t.Fatalf("splitFrontmatter(%q) ok = %v, want %v", tt.content, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if fm.format != tt.format || string(fm.raw) != tt.raw || string(fm.body) != tt.body || fm.line != tt.line {
+				t.Errorf("splitFrontmatter(%q) = %v %q %q line %v, want %v %q %q line %v",
+					tt.content, fm.format, fm.raw, fm.body, fm.line, tt.format, tt.raw, tt.body, tt.line)
+			}
+		})
+	}
+}
+
+func TestIsYAMLMapping(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want bool
+	}{
+		{"mapping", "title: Hello\n", true},
+		{"empty", "", true},
+		{"paragraph between horizontal rules", "\nSome text\n\n", false},
+		{"list", "- a\n- b\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isYAMLMapping([]byte(tt.raw)); got != tt.want {
+				t.Errorf("isYAMLMapping(%q) = %v, want %v", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMeta(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		meta    map[string]interface{}
+		body    string
+		errLine int
+	}{
+		{
+			name:    "yaml",
+			content: "---\ntitle: Hello\n---\nbody",
+			meta:    map[string]interface{}{"title": "Hello"},
+			body:    "body",
+		},
+		{
+			name:    "horizontal rule",
+			content: "---\n\nSome text\n\n---\nmore",
+			body:    "---\n\nSome text\n\n---\nmore",
+		},
+		{
+			name:    "yaml error",
+			content: "---\ntitle: Hello\nbad: : value\n---\nbody",
+			errLine: 3,
+		},
+		{
+			name:    "toml error",
+			content: "+++\ntitle = \"Hello\"\nnot valid\n+++\nbody",
+			errLine: 3,
+		},
+		{
+			name:    "json error",
+			content: "{\n  \"title\": \"Hello\",\n  oops\n}\nbody",
+			errLine: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			af := &AlvuFile{sourcePath: "pages/index.md", content: []byte(tt.content)}
+			err := af.ParseMeta()
+
+			if tt.errLine != 0 {
+				var fmErr *FrontmatterError
+				if !errors.As(err, &fmErr) {
+					t.Fatalf("ParseMeta() error = %v, want a FrontmatterError", err)
+				}
+				if fmErr.File != "pages/index.md" || fmErr.Line != tt.errLine {
+					t.Errorf("ParseMeta() error at %v:%v, want pages/index.md:%v", fmErr.File, fmErr.Line, tt.errLine)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseMeta() error = %v", err)
+			}
+			if len(af.meta) != len(tt.meta) {
+				t.Errorf("ParseMeta() meta = %v, want %v", af.meta, tt.meta)
+			}
+			for key, value := range tt.meta {
+				if af.meta[key] != value {
+					t.Errorf("ParseMeta() meta[%v] = %v, want %v", key, af.meta[key], value)
+				}
+			}
+			if string(af.writeableContent) != tt.body {
+				t.Errorf("ParseMeta() body = %q, want %q", af.writeableContent, tt.body)
+			}
+		})
+	}
+}
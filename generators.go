@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	textTmpl "text/template"
+)
+
+// Generator creates a page for every entry of a data file, or
+// for every data file matching a glob, from a template
+type Generator struct {
+	// path of the data file in the data directory, or a glob
+	Data string `json:"data"`
+	// file the pages are built from, relative to the -path DIR
+	Template string `json:"template"`
+	// path of the pages in `pages` without the extension,
+	// executed as a template with the entry
+	URL string `json:"url"`
+	// title of the pages, executed as a template with the entry
+	Title string `json:"title"`
+}
+
+// generatorEntry is what the url and title of the generated page
+// are executed with, and the page gets as `.Data`
+type generatorEntry struct {
+	entry interface{}
+	// key of the entry in a map, or the name of the data file
+	key   string
+	index int
+}
+
+func (ge generatorEntry) data() map[string]interface{} {
+	return map[string]interface{}{
+		"entry": ge.entry,
+		"key":   ge.key,
+		"index": ge.index,
+	}
+}
+
+// GeneratePages replaces the pages created by the generators in the
+// site config, they are created again on every build as the data
+// could've changed
+func (al *Alvu) GeneratePages() error {
+	files := []*AlvuFile{}
+	for _, af := range al.files {
+		if af.generator == nil {
+			files = append(files, af)
+		}
+	}
+	al.files, al.filesIndex = nil, nil
+	for _, af := range files {
+		al.AddFile(af)
+	}
+
+	for _, generator := range al.config.Generators {
+		if err := al.generate(generator); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (al *Alvu) generate(generator *Generator) error {
+	if generator.Data == "" || generator.Template == "" || generator.URL == "" {
+		return fmt.Errorf("generators need the `data`, `template` and `url` to create pages")
+	}
+
+	templatePath := filepath.Join(basePath, filepath.FromSlash(generator.Template))
+	if _, err := os.Stat(templatePath); err != nil {
+		return fmt.Errorf("generator template %v", err)
+	}
+	ext := filepath.Ext(templatePath)
+	if ext != ".md" && ext != ".html" {
+		return fmt.Errorf("%v: generator templates have to be markdown or html files", templatePath)
+	}
+
+	urlTmpl, err := textTmpl.New("url").Funcs(textTmpl.FuncMap(templateFuncs)).Parse(generator.URL)
+	if err != nil {
+		return fmt.Errorf("generator url %v", err)
+	}
+	titleTmpl, err := textTmpl.New("title").Funcs(textTmpl.FuncMap(templateFuncs)).Parse(generator.Title)
+	if err != nil {
+		return fmt.Errorf("generator title %v", err)
+	}
+
+	entries, err := al.generatorEntries(generator.Data)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		var url bytes.Buffer
+		if err := urlTmpl.Execute(&url, entry.data()); err != nil {
+			return fmt.Errorf("generator url %v", err)
+		}
+		name := strings.Trim(path.Clean("/"+strings.TrimSpace(url.String())), "/")
+		if strings.HasSuffix(url.String(), "/") || name == "" {
+			name = path.Join(name, "index")
+		}
+		name += ext
+
+		sourcePath := filepath.Join(al.pagesPath, filepath.FromSlash(name))
+		if existing := al.fileAt(sourcePath); existing != nil {
+			return fmt.Errorf("generator url `%v` for %v is already used by %v", name, generator.Data, existing.templateOrSourcePath())
+		}
+
+		af := al.NewFile(sourcePath, name)
+		af.generator = generator
+		af.templatePath = templatePath
+		af.data = entry.data()
+		if generator.Title != "" {
+			var title bytes.Buffer
+			if err := titleTmpl.Execute(&title, entry.data()); err != nil {
+				return fmt.Errorf("generator title %v", err)
+			}
+			af.generatedMeta = map[string]interface{}{"title": title.String()}
+		}
+		al.AddFile(af)
+	}
+	return nil
+}
+
+// generatorEntries returns the items of a list or the values of a map
+// in a data file, for a glob it's the content of every matching file
+func (al *Alvu) generatorEntries(pattern string) ([]generatorEntry, error) {
+	dataPath := filepath.Join(al.dataPath, filepath.FromSlash(pattern))
+
+	if strings.ContainsAny(pattern, "*?[") {
+		matches, err := filepath.Glob(dataPath)
+		if err != nil {
+			return nil, fmt.Errorf("generator data %v", err)
+		}
+		sort.Strings(matches)
+
+		entries := []generatorEntry{}
+		for _, match := range matches {
+			value, err := readDataFile(match)
+			if err != nil {
+				return nil, err
+			}
+			if value == nil {
+				continue
+			}
+			key := strings.TrimSuffix(filepath.Base(match), filepath.Ext(match))
+			entries = append(entries, generatorEntry{entry: value, key: key, index: len(entries)})
+		}
+		return entries, nil
+	}
+
+	if _, ok := dataFormats[strings.ToLower(filepath.Ext(dataPath))]; !ok {
+		return nil, fmt.Errorf("%v: generators need a yaml, json, toml or csv file", dataPath)
+	}
+	value, err := readDataFile(dataPath)
+	if err != nil {
+		return nil, err
+	}
+	entries := []generatorEntry{}
+	switch v := value.(type) {
+	case []interface{}:
+		for i, item := range v {
+			entries = append(entries, generatorEntry{entry: item, key: fmt.Sprint(i), index: i})
+		}
+	case map[string]interface{}:
+		keys := []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for i, key := range keys {
+			entries = append(entries, generatorEntry{entry: v[key], key: key, index: i})
+		}
+	default:
+		return nil, fmt.Errorf("%v: generators need a list or a map of entries", dataPath)
+	}
+	return entries, nil
+}
+
+// readDataFile reads a file with one of the data formats,
+// nil is returned for files in other formats
+func readDataFile(filePath string) (interface{}, error) {
+	format, ok := dataFormats[strings.ToLower(filepath.Ext(filePath))]
+	if !ok {
+		return nil, nil
+	}
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	value, err := parseDataFile(format, content)
+	if err != nil {
+		return nil, fmt.Errorf("%v:%v", filePath, err)
+	}
+	return value, nil
+}
+
+// templateOrSourcePath is the file the page is read from
+func (af *AlvuFile) templateOrSourcePath() string {
+	if af.templatePath != "" {
+		return af.templatePath
+	}
+	return af.sourcePath
+}
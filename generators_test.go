@@ -0,0 +1,133 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGeneratePages(t *testing.T) {
+	previous := basePath
+	defer func() { basePath = previous }()
+
+	tests := []struct {
+		name      string
+		generator Generator
+		// `name title` of the generated pages
+		want    []string
+		wantErr string
+	}{
+		{
+			name:      "list",
+			generator: Generator{Data: "people.yml", Template: "person.md", URL: "people/{{ .entry.id }}", Title: "{{ .entry.name }}"},
+			want:      []string{"people/ann.md Ann", "people/bob.md Bob"},
+		},
+		{
+			name:      "map",
+			generator: Generator{Data: "endpoints.json", Template: "person.md", URL: "api/{{ .key }}/", Title: "{{ .index }}"},
+			want:      []string{"api/get/index.md 0", "api/list/index.md 1"},
+		},
+		{
+			name:      "glob",
+			generator: Generator{Data: "posts/*", Template: "person.html", URL: "/posts/{{ .key }}"},
+			want:      []string{"posts/a.html ", "posts/b.html "},
+		},
+		{
+			name:      "missing url",
+			generator: Generator{Data: "people.yml", Template: "person.md"},
+			wantErr:   "generators need the `data`, `template` and `url`",
+		},
+		{
+			name:      "missing template",
+			generator: Generator{Data: "people.yml", Template: "nope.md", URL: "{{ .key }}"},
+			wantErr:   "generator template",
+		},
+		{
+			name:      "template that isn't a page",
+			generator: Generator{Data: "people.yml", Template: "data/people.yml", URL: "{{ .key }}"},
+			wantErr:   "have to be markdown or html files",
+		},
+		{
+			name:      "url of an existing page",
+			generator: Generator{Data: "people.yml", Template: "person.md", URL: "about"},
+			wantErr:   "generator url `about.md` for people.yml is already used by",
+		},
+		{
+			name:      "same url for every entry",
+			generator: Generator{Data: "people.yml", Template: "person.md", URL: "person"},
+			wantErr:   "generator url `person.md` for people.yml is already used by",
+		},
+		{
+			name:      "data that isn't a list or a map",
+			generator: Generator{Data: "name.yml", Template: "person.md", URL: "{{ .key }}"},
+			wantErr:   "generators need a list or a map of entries",
+		},
+		{
+			name:      "data that isn't a data file",
+			generator: Generator{Data: "notes.txt", Template: "person.md", URL: "{{ .key }}"},
+			wantErr:   "generators need a yaml, json, toml or csv file",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			basePath = t.TempDir()
+			writeFiles(t, basePath, map[string]string{
+				"person.md":           "# {{ .Data.entry.name }}",
+				"person.html":         "<p>{{ .Data.key }}</p>",
+				"pages/about.md":      "",
+				"data/people.yml":     "- {id: ann, name: Ann}\n- {id: bob, name: Bob}",
+				"data/endpoints.json": `{"list": {}, "get": {}}`,
+				"data/posts/a.yml":    "title: A",
+				"data/posts/b.toml":   "title = \"B\"",
+				"data/posts/notes.md": "",
+				"data/name.yml":       "alvu",
+				"data/notes.txt":      "",
+			})
+
+			generator := tt.generator
+			al := &Alvu{
+				pagesPath: filepath.Join(basePath, "pages"),
+				dataPath:  filepath.Join(basePath, "data"),
+				config:    &SiteConfig{Generators: []*Generator{&generator}},
+			}
+			about := al.NewFile(filepath.Join(al.pagesPath, "about.md"), "about.md")
+			al.AddFile(about)
+
+			err := al.GeneratePages()
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("GeneratePages() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, af := range al.files {
+				if af.generator == nil {
+					continue
+				}
+				if err := af.Load(); err != nil {
+					t.Fatal(err)
+				}
+				title, _ := af.meta["title"].(string)
+				got = append(got, filepath.ToSlash(af.name)+" "+title)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GeneratePages() = %q, want %q", got, tt.want)
+			}
+
+			// the pages are replaced on the next build
+			if err := al.GeneratePages(); err != nil {
+				t.Fatal(err)
+			}
+			if len(al.files) != len(tt.want)+1 || al.files[0] != about {
+				t.Errorf("GeneratePages() again has %v files, want %v", len(al.files), len(tt.want)+1)
+			}
+		})
+	}
+}
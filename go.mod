@@ -5,6 +5,8 @@ go 1.22
 toolchain go1.24.2
 
 require (
+	github.com/BurntSushi/toml v1.4.0
+	github.com/alecthomas/chroma v0.10.0
 	github.com/barelyhuman/go v0.2.2-0.20230713173609-2ee88bb52634
 	github.com/cjoudrey/gluahttp v0.0.0-20201111170219-25003d9adfa9
 	github.com/joho/godotenv v1.5.1
@@ -18,7 +20,6 @@ require (
 )
 
 require (
-	github.com/alecthomas/chroma v0.10.0 // indirect
 	github.com/dlclark/regexp2 v1.4.0 // indirect
 	gopkg.in/yaml.v2 v2.3.0 // indirect
 )
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	chromahtml "github.com/alecthomas/chroma/formatters/html"
+	"github.com/alecthomas/chroma/styles"
+	highlighting "github.com/yuin/goldmark-highlighting"
+	"github.com/yuin/goldmark/renderer"
+)
+
+// HighlightConfig is the `highlight` key of the markdown config,
+// it only applies when highlighting is enabled with `-highlight`
+type HighlightConfig struct {
+	// use classes instead of inline styles, the styles
+	// are written to a css file in the output
+	Classes bool `json:"classes"`
+	// replaces the `-highlight-theme`
+	Theme string `json:"theme"`
+	// used under `prefers-color-scheme: dark`, only with classes
+	DarkTheme string `json:"dark_theme"`
+	// path of the css file in the output
+	CSSFile string `json:"css_file"`
+}
+
+func (hc HighlightConfig) theme() string {
+	if hc.Theme != "" {
+		return hc.Theme
+	}
+	return mdSettings.theme
+}
+
+func (hc HighlightConfig) cssFile() string {
+	if hc.CSSFile != "" {
+		return hc.CSSFile
+	}
+	return "chroma.css"
+}
+
+// newHighlightRenderer creates the highlighting renderer with
+// the line numbers and highlighted lines taken from the attributes
+// of the fence, `go {linenos=true hl_lines=[2,"4-6"]}`
+func newHighlightRenderer(config HighlightConfig) renderer.NodeRenderer {
+	options := []highlighting.Option{
+		highlighting.WithStyle(config.theme()),
+	}
+	if config.Classes {
+		options = append(options, highlighting.WithFormatOptions(
+			chromahtml.WithClasses(true),
+		))
+	}
+	return highlighting.NewHTMLRenderer(options...)
+}
+
+// writeHighlightCSS writes the styles for the themes when highlighting
+// with classes, the dark theme is wrapped in a media query
+func writeHighlightCSS(outPath string, config HighlightConfig) error {
+	css, err := highlightCSS(config.theme())
+	if err != nil {
+		return err
+	}
+
+	if config.DarkTheme != "" {
+		darkCSS, err := highlightCSS(config.DarkTheme)
+		if err != nil {
+			return err
+		}
+		css = append(css, "\n@media (prefers-color-scheme: dark) {\n"...)
+		scanner := bufio.NewScanner(bytes.NewReader(darkCSS))
+		for scanner.Scan() {
+			css = append(css, "  "+scanner.Text()+"\n"...)
+		}
+		css = append(css, "}\n"...)
+	}
+
+	cssPath := filepath.Join(outPath, filepath.FromSlash(config.cssFile()))
+	if err := os.MkdirAll(filepath.Dir(cssPath), os.ModePerm); err != nil {
+		return err
+	}
+	return os.WriteFile(cssPath, css, 0644)
+}
+
+// highlightCSS returns the class based styles of the theme
+func highlightCSS(theme string) ([]byte, error) {
+	style, ok := styles.Registry[theme]
+	if !ok {
+		return nil, fmt.Errorf("unknown highlight theme `%v`", theme)
+	}
+
+	formatter := chromahtml.New(
+		chromahtml.WithClasses(true),
+		chromahtml.WithLineNumbers(true),
+		chromahtml.LineNumbersInTable(true),
+	)
+	var css bytes.Buffer
+	if err := formatter.WriteCSS(&css, style); err != nil {
+		return nil, err
+	}
+
+	// chroma leaves out the newline after the table rule
+	return bytes.ReplaceAll(css.Bytes(), []byte("}/*"), []byte("}\n/*")), nil
+}
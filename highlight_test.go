@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+func TestHighlightRenderer(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  HighlightConfig
+		content string
+		// parts the output should have
+		want []string
+	}{
+		{
+			name:    "classes",
+			config:  HighlightConfig{Theme: "monokai", Classes: true},
+			content: "```go\nfunc main() {}\n```",
+			want:    []string{`<pre tabindex="0" class="chroma">`, `<span class="kd">func</span>`},
+		},
+		{
+			name:    "inline styles",
+			config:  HighlightConfig{Theme: "monokai"},
+			content: "```go\nfunc main() {}\n```",
+			want:    []string{`<pre tabindex="0" style="`, `<span style="color:#66d9ef">func</span>`},
+		},
+		{
+			name:    "line numbers and highlighted lines",
+			config:  HighlightConfig{Theme: "monokai", Classes: true},
+			content: "```go {linenos=table hl_lines=[2]}\na\nb\n```",
+			want:    []string{`<table class="lntable">`, `<span class="line hl">`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := goldmark.New(
+				goldmark.WithParserOptions(parser.WithASTTransformers(
+					util.Prioritized(&codeBlockAttributes{}, 100),
+				)),
+				goldmark.WithRendererOptions(renderer.WithNodeRenderers(
+					util.Prioritized(newHighlightRenderer(tt.config), 150),
+				)),
+			)
+
+			var got bytes.Buffer
+			if err := md.Convert([]byte(tt.content), &got); err != nil {
+				t.Fatal(err)
+			}
+			for _, part := range tt.want {
+				if !strings.Contains(got.String(), part) {
+					t.Errorf("Convert(%q) = %q, want it to contain %q", tt.content, got.String(), part)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteHighlightCSS(t *testing.T) {
+	tests := []struct {
+		name   string
+		config HighlightConfig
+		file   string
+		want   []string
+		// parts the css shouldn't have
+		notWant []string
+	}{
+		{
+			name:    "theme",
+			config:  HighlightConfig{Theme: "monokai"},
+			file:    "chroma.css",
+			want:    []string{"/* PreWrapper */ .chroma { color: #f8f8f2; background-color: #272822; }", "}\n/* LineTableTD */"},
+			notWant: []string{"@media"},
+		},
+		{
+			name:   "dark theme",
+			config: HighlightConfig{Theme: "github", DarkTheme: "monokai", CSSFile: "css/code.css"},
+			file:   "css/code.css",
+			want:   []string{"background-color: #ffffff", "@media (prefers-color-scheme: dark) {\n  /* Background */", "\n  /* PreWrapper */ .chroma { color: #f8f8f2; background-color: #272822; }"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := t.TempDir()
+			if err := writeHighlightCSS(out, tt.config); err != nil {
+				t.Fatal(err)
+			}
+			css, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(tt.file)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, part := range tt.want {
+				if !strings.Contains(string(css), part) {
+					t.Errorf("css doesn't contain %q", part)
+				}
+			}
+			for _, part := range tt.notWant {
+				if strings.Contains(string(css), part) {
+					t.Errorf("css contains %q", part)
+				}
+			}
+		})
+	}
+
+	if err := writeHighlightCSS(t.TempDir(), HighlightConfig{Theme: "nope"}); err == nil {
+		t.Error("writeHighlightCSS() with an unknown theme didn't return an error")
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/lexers"
+)
+
+// includeParams are the params of the include shortcode
+// that aren't passed on to the code block
+var includeParams = map[string]bool{
+	"file":   true,
+	"lines":  true,
+	"region": true,
+	"lang":   true,
+}
+
+// bareAttributePattern matches values that are written into the
+// info string as they are, numbers, booleans and lists
+var bareAttributePattern = regexp.MustCompile(`^(?:-?\d+(?:\.\d+)?|true|false|\[.*\])$`)
+
+// includeShortcode is the built in `include` shortcode, it puts
+// a file or a part of it into a fenced code block
+//
+//	{{% include "examples/main.go" lines="5-12" title="main.go" %}}
+//	{{% include "examples/main.go" region="setup" %}}
+//
+// Paths are relative to the page, or to the `-path` directory
+// when they start with `/` or aren't found next to the page
+func includeShortcode(data *ShortcodeData) (string, error) {
+	af := data.expansion.file
+	name := data.Get("file")
+	if name == "" {
+		name = data.Get(0)
+	}
+	if name == "" {
+		return "", fmt.Errorf("missing the file to include")
+	}
+
+	filePath := af.resolveFile(name)
+	data.expansion.page.includes = append(data.expansion.page.includes, filePath)
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	lines := strings.SplitAfter(strings.TrimSuffix(string(content), "\n"), "\n")
+	if region := data.Get("region"); region != "" {
+		lines, err = includeRegion(lines, region)
+		if err != nil {
+			return "", fmt.Errorf("%v: %v", filePath, err)
+		}
+	}
+	if lineRange := data.Get("lines"); lineRange != "" {
+		lines, err = includeLines(lines, lineRange)
+		if err != nil {
+			return "", fmt.Errorf("%v: %v", filePath, err)
+		}
+	}
+	code := strings.Join(dedentLines(lines), "")
+	if !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+
+	lang := data.Get("lang")
+	if lang == "" {
+		lang = includeLanguage(filePath)
+	}
+
+	info := []string{lang}
+	keys := []string{}
+	for key := range data.Params {
+		if !includeParams[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := data.Params[key]
+		if !bareAttributePattern.MatchString(value) {
+			value = strconv.Quote(value)
+		}
+		info = append(info, key+"="+value)
+	}
+
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	block := fence + strings.Join(info, " ") + "\n" + code + fence + "\n"
+
+	if data.markdown {
+		return block, nil
+	}
+	md, err := data.expansion.page.markdownProcessor()
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := md.Convert([]byte(block), &buf); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// resolveFile finds the file an include or embed points to,
+// the path relative to the page is returned when neither exist
+func (af *AlvuFile) resolveFile(name string) string {
+	fromRoot := filepath.Join(basePath, filepath.FromSlash(strings.TrimPrefix(name, "/")))
+	if strings.HasPrefix(name, "/") {
+		return fromRoot
+	}
+	fromPage := filepath.Join(filepath.Dir(af.sourcePath), filepath.FromSlash(name))
+	if _, err := os.Stat(fromPage); err != nil {
+		if _, err := os.Stat(fromRoot); err == nil {
+			return fromRoot
+		}
+	}
+	return fromPage
+}
+
+// includeLines picks the lines of a range, `5-12`, `5-`,
+// `-12` or just `5`, line numbers start at 1
+func includeLines(lines []string, lineRange string) ([]string, error) {
+	startText, endText, isRange := strings.Cut(lineRange, "-")
+	start, end := 1, len(lines)
+	var err error
+	if startText != "" {
+		if start, err = strconv.Atoi(strings.TrimSpace(startText)); err != nil {
+			return nil, fmt.Errorf("invalid line range `%v`", lineRange)
+		}
+	}
+	if !isRange {
+		end = start
+	} else if endText != "" {
+		if end, err = strconv.Atoi(strings.TrimSpace(endText)); err != nil {
+			return nil, fmt.Errorf("invalid line range `%v`", lineRange)
+		}
+	}
+
+	if start < 1 || start > len(lines) || end < start {
+		return nil, fmt.Errorf("line range `%v` is outside of the %v line(s) in the file", lineRange, len(lines))
+	}
+	return lines[start-1 : min(end, len(lines))], nil
+}
+
+// includeRegion picks the lines between the `#region name` and
+// `#endregion` comments, marker lines of other regions are dropped
+func includeRegion(lines []string, name string) ([]string, error) {
+	start := -1
+	region := []string{}
+	for i, line := range lines {
+		marker, markerName := regionMarker(line)
+		if start == -1 {
+			if marker == "#region" && markerName == name {
+				start = i
+			}
+			continue
+		}
+		if marker == "#endregion" && (markerName == "" || markerName == name) {
+			return region, nil
+		}
+		if marker == "" {
+			region = append(region, line)
+		}
+	}
+	if start == -1 {
+		return nil, fmt.Errorf("no region named `%v`", name)
+	}
+	return nil, fmt.Errorf("region `%v` is never closed with `#endregion`", name)
+}
+
+// regionMarker returns the marker in a line and the name after it,
+// comment characters after the name like `-->` are left out
+func regionMarker(line string) (marker string, name string) {
+	for _, m := range []string{"#endregion", "#region"} {
+		index := strings.Index(line, m)
+		if index == -1 {
+			continue
+		}
+		fields := strings.Fields(line[index+len(m):])
+		if len(fields) > 0 && fields[0] != "-->" && fields[0] != "*/" {
+			name = fields[0]
+		}
+		return m, name
+	}
+	return "", ""
+}
+
+// dedentLines removes the indentation all the lines have in
+// common, for parts of a file that are nested in something
+func dedentLines(lines []string) []string {
+	indent := ""
+	first := true
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lineIndent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		if first {
+			indent, first = lineIndent, false
+			continue
+		}
+		for !strings.HasPrefix(lineIndent, indent) {
+			indent = indent[:len(indent)-1]
+		}
+	}
+	if indent == "" {
+		return lines
+	}
+
+	dedented := make([]string, len(lines))
+	for i, line := range lines {
+		dedented[i] = strings.TrimPrefix(line, indent)
+	}
+	return dedented
+}
+
+// includeLanguage is the language of the code block
+// for a file, guessed from its name
+func includeLanguage(filePath string) string {
+	if lexer := lexers.Match(filepath.Base(filePath)); lexer != nil {
+		if aliases := lexer.Config().Aliases; len(aliases) > 0 {
+			return aliases[0]
+		}
+		return strings.ToLower(lexer.Config().Name)
+	}
+	return strings.TrimPrefix(filepath.Ext(filePath), ".")
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIncludeShortcode(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.go":    "package main\n\nfunc main() {\n\t// #region setup\n\tx := 1\n\t// #endregion\n}\n",
+		"fence.md":   "```go\nx\n```",
+		"config.xyz": "a: 1",
+		"nested.html": "<div>\n  <!-- #region item -->\n  <p>one</p>\n  <!-- #region other -->\n  <p>two</p>\n  <!-- #endregion other -->\n" +
+			"  <!-- #endregion item -->\n</div>",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "whole file",
+			content: "{{% include \"main.go\" %}}",
+			want:    "```go\npackage main\n\nfunc main() {\n\t// #region setup\n\tx := 1\n\t// #endregion\n}\n```\n",
+		},
+		{
+			name:    "lines",
+			content: "{{% include file=\"main.go\" lines=\"3-4\" %}}",
+			want:    "```go\nfunc main() {\n\t// #region setup\n```\n",
+		},
+		{
+			name:    "single line and open range",
+			content: "{{% include \"main.go\" lines=\"5\" %}} {{% include \"main.go\" lines=\"7-\" %}}",
+			want:    "```go\nx := 1\n```\n ```go\n}\n```\n",
+		},
+		{
+			name:    "region",
+			content: "{{% include \"main.go\" region=\"setup\" %}}",
+			want:    "```go\nx := 1\n```\n",
+		},
+		{
+			name:    "nested regions are dedented",
+			content: "{{% include \"nested.html\" region=\"item\" %}}",
+			want:    "```html\n<p>one</p>\n<p>two</p>\n```\n",
+		},
+		{
+			name:    "attributes and language",
+			content: "{{% include \"config.xyz\" lang=\"yaml\" title=\"config.yml\" linenos=true hl_lines=[1] %}}",
+			want:    "```yaml hl_lines=[1] linenos=true title=\"config.yml\"\na: 1\n```\n",
+		},
+		{
+			name:    "language from the extension",
+			content: "{{% include \"config.xyz\" %}}",
+			want:    "```xyz\na: 1\n```\n",
+		},
+		{
+			name:    "fences in the file",
+			content: "{{% include \"fence.md\" %}}",
+			want:    "````md\n```go\nx\n```\n````\n",
+		},
+		{
+			name:    "html",
+			content: "{{< include \"config.xyz\" lang=\"yaml\" >}}",
+			want:    "<pre><code class=\"language-yaml\">a: 1\n</code></pre>\n",
+		},
+		{
+			name:    "missing file",
+			content: "{{% include \"nope.go\" %}}",
+			wantErr: "no such file",
+		},
+		{
+			name:    "end past the last line",
+			content: "{{% include \"main.go\" lines=\"5-20\" %}}",
+			want:    "```go\n\tx := 1\n\t// #endregion\n}\n```\n",
+		},
+		{
+			name:    "invalid range",
+			content: "{{% include \"main.go\" lines=\"9\" %}}",
+			wantErr: "line range `9` is outside of the 7 line(s) in the file",
+		},
+		{
+			name:    "missing region",
+			content: "{{% include \"main.go\" region=\"nope\" %}}",
+			wantErr: "no region named `nope`",
+		},
+	}
+
+	initMDProcessor(false, "", MarkdownConfig{})
+	shortcodes := ShortcodeCollection{"include": &Shortcode{builtin: includeShortcode}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			af := &AlvuFile{sourcePath: filepath.Join(dir, "page.md"), alvu: &Alvu{}}
+			verbatim := &verbatimStore{}
+			expansion, got, err := shortcodes.Expand(af, []byte(tt.content), PageRenderData{}, verbatim)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expand() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = expansion.RestoreHTML(verbatim.Restore(got))
+			if string(got) != tt.want {
+				t.Errorf("Expand() = %q, want %q", got, tt.want)
+			}
+			if len(af.includes) == 0 || af.includes[0] != filepath.Join(dir, strings.Split(tt.content, "\"")[1]) {
+				t.Errorf("includes = %q, want the included file", af.includes)
+			}
+		})
+	}
+}
+
+func TestDedentLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines []string
+		want  []string
+	}{
+		{"common indent", []string{"  a\n", "    b\n", "  c"}, []string{"a\n", "  b\n", "c"}},
+		{"blank lines are ignored", []string{"\ta\n", "\n", "\tb"}, []string{"a\n", "\n", "b"}},
+		{"mixed indent", []string{"\t a\n", "\tb"}, []string{" a\n", "b"}},
+		{"no indent", []string{"a\n", "  b"}, []string{"a\n", "  b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dedentLines(tt.lines); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dedentLines(%q) = %q, want %q", tt.lines, got, tt.want)
+			}
+		})
+	}
+}
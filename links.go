@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// linkFileKey holds the file being parsed, links are only
+// resolved when the markdown belongs to a page
+var linkFileKey = parser.NewContextKey()
+
+// linkResolver rewrites relative links to other source pages,
+// `[basics](01-basics.md#install)`, into the url the page is built
+// at. Links to markdown files that don't exist are collected on the
+// file so they can be reported
+type linkResolver struct{}
+
+func (lr *linkResolver) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	af, ok := pc.Get(linkFileKey).(*AlvuFile)
+	if !ok || af == nil || af.alvu == nil {
+		return
+	}
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		link, ok := n.(*ast.Link)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+
+		destination := string(link.Destination)
+		resolved, found := af.resolveLink(destination)
+		switch {
+		case found:
+			link.Destination = []byte(resolved)
+		case resolved != "":
+			af.brokenLinks = append(af.brokenLinks, destination)
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// resolveLink returns the url for a link relative to the file. For
+// links to markdown files that aren't a page, the path of the missing
+// file is returned with found set to false
+func (af *AlvuFile) resolveLink(destination string) (resolved string, found bool) {
+	if destination == "" ||
+		strings.HasPrefix(destination, "#") ||
+		strings.HasPrefix(destination, "/") {
+		return "", false
+	}
+
+	parsed, err := url.Parse(destination)
+	if err != nil || parsed.Scheme != "" || parsed.Host != "" {
+		return "", false
+	}
+
+	ext := filepath.Ext(parsed.Path)
+	if ext != ".md" && ext != ".html" {
+		return "", false
+	}
+
+	targetPath := filepath.Join(filepath.Dir(af.sourcePath), filepath.FromSlash(parsed.Path))
+	target := af.alvu.fileAt(targetPath)
+	if target == nil {
+		// html files could be anything from the public directory
+		if ext == ".md" {
+			return targetPath, false
+		}
+		return "", false
+	}
+
+	resolved = target.URL()
+	if parsed.RawQuery != "" {
+		resolved += "?" + parsed.RawQuery
+	}
+	if parsed.Fragment != "" {
+		resolved += "#" + parsed.EscapedFragment()
+	}
+	return resolved, true
+}
+
+// fileAt returns the page with the given source path
+func (al *Alvu) fileAt(sourcePath string) *AlvuFile {
+	sourcePath = filepath.Clean(sourcePath)
+	for _, af := range al.files {
+		if filepath.Clean(af.sourcePath) == sourcePath {
+			return af
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestResolveLink(t *testing.T) {
+	baseurl = "/site/"
+	defer func() { baseurl = "" }()
+
+	al := &Alvu{pagesPath: "pages"}
+	for _, name := range []string{"index.md", "01-basics.md", "guides/_index.md", "guides/setup.md", "about.html"} {
+		al.AddFile(al.NewFile("pages/"+name, name))
+	}
+	af := al.files[3]
+
+	tests := []struct {
+		name        string
+		destination string
+		want        string
+		found       bool
+	}{
+		{"sibling", "_index.md", "/site/guides/", true},
+		{"parent directory", "../01-basics.md", "/site/basics/", true},
+		{"fragment", "../01-basics.md#install", "/site/basics/#install", true},
+		{"query", "../01-basics.md?v=1#a%20b", "/site/basics/?v=1#a%20b", true},
+		{"index", "../index.md", "/site/", true},
+		{"html page", "../about.html", "/site/about/", true},
+		{"missing markdown", "../missing.md", "pages/missing.md", false},
+		{"missing html", "../public.html", "", false},
+		{"other files", "../image.png", "", false},
+		{"anchor", "#top", "", false},
+		{"site relative", "/01-basics.md", "", false},
+		{"external", "https://x.dev/a.md", "", false},
+		{"empty", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := af.resolveLink(tt.destination)
+			if got != tt.want || found != tt.found {
+				t.Errorf("resolveLink(%q) = %q, %v, want %q, %v", tt.destination, got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestLinkResolver(t *testing.T) {
+	initMDProcessor(false, "", MarkdownConfig{})
+
+	al := &Alvu{pagesPath: "pages"}
+	for _, name := range []string{"index.md", "01-basics.md"} {
+		al.AddFile(al.NewFile("pages/"+name, name))
+	}
+	af := al.files[0]
+
+	source := []byte("[basics](01-basics.md#install) [gone](gone.md) ![img](01-basics.md) `[code](01-basics.md)`")
+	var got bytes.Buffer
+	if err := mdProcessor.Renderer().Render(&got, source, parseMarkdown(mdProcessor, source, af)); err != nil {
+		t.Fatal(err)
+	}
+	want := "<p><a href=\"/basics/#install\">basics</a> <a href=\"gone.md\">gone</a> <img src=\"01-basics.md\" alt=\"img\" /> <code>[code](01-basics.md)</code></p>\n"
+	if got.String() != want {
+		t.Errorf("Render() = %q, want %q", got.String(), want)
+	}
+	if !reflect.DeepEqual(af.brokenLinks, []string{"gone.md"}) {
+		t.Errorf("brokenLinks = %q, want %q", af.brokenLinks, []string{"gone.md"})
+	}
+}
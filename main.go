@@ -7,7 +7,6 @@ import (
 	"flag"
 	"fmt"
 	"html/template"
-	textTmpl "text/template"
 	"io"
 	"io/fs"
 	"log"
@@ -18,6 +17,8 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	textTmpl "text/template"
+	"time"
 
 	_ "embed"
 
@@ -35,11 +36,9 @@ import (
 	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/renderer/html"
-
-	highlighting "github.com/yuin/goldmark-highlighting"
+	"github.com/yuin/goldmark/util"
 
 	lua "github.com/yuin/gopher-lua"
-	"gopkg.in/yaml.v3"
 
 	luaAlvu "github.com/barelyhuman/alvu/lua/alvu"
 	"golang.org/x/net/websocket"
@@ -69,6 +68,8 @@ type SiteMeta struct {
 
 type PageRenderData struct {
 	Meta   SiteMeta
+	Site   SiteData
+	Page   *PageData
 	Data   map[string]interface{}
 	Extras map[string]interface{}
 }
@@ -82,12 +83,49 @@ type LayoutRenderData struct {
 // on each newly added feature or during improving
 // older features.
 type Alvu struct {
-	publicPath string
-	files      []*AlvuFile
-	filesIndex []string
+	publicPath     string
+	pagesPath      string
+	shortcodesPath string
+	dataPath       string
+	configPath     string
+	config         *SiteConfig
+	files          []*AlvuFile
+	filesIndex     []string
+	schemas        SchemaCollection
+	shortcodes     ShortcodeCollection
+	data           map[string]interface{}
+	root           *PageData
+	menus          Menus
+	// layout files every page is built with
+	headContent      []byte
+	tailContent      []byte
+	baseTemplateData []byte
+	// pages by the names wiki links can use for them
+	wikiTargets map[string]*AlvuFile
+	// file whose content is being rendered
+	rendering *AlvuFile
+}
+
+// NewFile creates a file for the source path, the
+// name is the path relative to `pages`
+func (al *Alvu) NewFile(sourcePath string, name string) *AlvuFile {
+	return &AlvuFile{
+		lock:             &sync.Mutex{},
+		sourcePath:       sourcePath,
+		hooks:            hookCollection,
+		destPath:         filepath.Join(outPath, stripOrderPrefixes(name)),
+		name:             name,
+		isHTML:           strings.HasSuffix(name, ".html"),
+		headContent:      al.headContent,
+		tailContent:      al.tailContent,
+		baseTemplateData: al.baseTemplateData,
+		data:             map[string]interface{}{},
+		extras:           map[string]interface{}{},
+	}
 }
 
 func (al *Alvu) AddFile(file *AlvuFile) {
+	file.alvu = al
 	al.files = append(al.files, file)
 	al.filesIndex = append(al.filesIndex, file.sourcePath)
 }
@@ -101,10 +139,73 @@ func (al *Alvu) IsAlvuFile(filePath string) bool {
 	return false
 }
 
-func (al *Alvu) Build() {
+// Build loads and writes all the files, the errors that come from
+// the content of the site are returned to be reported while serving
+func (al *Alvu) Build() error {
+	// the data is needed to create the generated pages
+	data, err := CollectData(al.dataPath)
+	if err != nil {
+		return err
+	}
+	al.data = data
+	if err := al.GeneratePages(); err != nil {
+		return err
+	}
+
+	for _, alvuFile := range al.files {
+		if err := alvuFile.Load(); err != nil {
+			return err
+		}
+	}
+
+	// schemas could've changed since the last build
+	al.schemas = nil
+
+	// so could the shortcodes
+	al.shortcodes.Shutdown()
+	shortcodes, err := CollectShortcodes(al.shortcodesPath)
+	if err != nil {
+		return err
+	}
+	al.shortcodes = shortcodes
+
+	renderHooks, err := CollectRenderHooks(al.pagesPath)
+	if err != nil {
+		return err
+	}
+	setRenderHooks(renderHooks)
+
+	if mdSettings.highlight && mdSettings.config.Highlight.Classes {
+		if err := writeHighlightCSS(outPath, mdSettings.config.Highlight); err != nil {
+			return err
+		}
+	}
+
+	if err := al.ApplyDefaults(al.files...); err != nil {
+		return err
+	}
+	if err := al.ValidateMeta(al.files...); err != nil {
+		return err
+	}
+	al.BuildPageTree()
+	al.menus = al.BuildMenus()
+
+	for ind := range al.files {
+		alvuFile := al.files[ind]
+		alvuFile.RunHooks()
+	}
+	if err := al.CheckOutputPaths(); err != nil {
+		return err
+	}
+
+	// the content is rendered from what the hooks left
+	for _, alvuFile := range al.files {
+		alvuFile.rendered = nil
+	}
+
 	for ind := range al.files {
 		alvuFile := al.files[ind]
-		alvuFile.Build()
+		alvuFile.FlushFile()
 	}
 
 	onDebug(func() {
@@ -114,6 +215,47 @@ func (al *Alvu) Build() {
 
 	// right before completion run all hooks again but for the onFinish
 	hookCollection.RunAll("OnFinish")
+	return nil
+}
+
+// ApplyDefaults merges the directory defaults and
+// section cascades into the meta of the given files
+func (al *Alvu) ApplyDefaults(files ...*AlvuFile) error {
+	defaults, err := CollectDefaults(al.pagesPath, al.files)
+	if err != nil {
+		return err
+	}
+	for _, alvuFile := range files {
+		defaults.Apply(al.pagesPath, alvuFile)
+	}
+	return nil
+}
+
+// ValidateMeta checks the frontmatter of the given files against
+// the schemas in the pages directory and reports every violation
+func (al *Alvu) ValidateMeta(files ...*AlvuFile) error {
+	if al.schemas == nil {
+		schemas, err := CollectSchemas(al.pagesPath)
+		if err != nil {
+			return err
+		}
+		al.schemas = schemas
+	}
+
+	violations := []SchemaViolation{}
+	for _, alvuFile := range files {
+		violations = append(violations, al.schemas.Validate(alvuFile)...)
+	}
+
+	for _, violation := range violations {
+		cs := &color.ColorString{}
+		fmt.Fprintln(os.Stderr, cs.Red(logPrefix).Reset(" ").Yellow(violation.String()).String())
+	}
+
+	if len(violations) > 0 {
+		return fmt.Errorf("found %v frontmatter violation(s)", len(violations))
+	}
+	return nil
 }
 
 func (al *Alvu) CopyPublic() {
@@ -155,8 +297,21 @@ func main() {
 	hardWrapsFlag := flag.Bool("hard-wrap", true, "enable hard wrapping of elements with `<br>`")
 	portFlag := flag.String("port", "3000", "`PORT` to start the server on")
 	pollDurationFlag := flag.Int("poll", 350, "Polling duration for file changes in milliseconds")
+	externalLinksFlag := flag.Bool("external", false, "also request external links when running check-links")
+	configFlag := flag.String("config", "", "`FILE` to read the site config from (default alvu.yml, alvu.toml or alvu.json in the -path DIR)")
 
-	flag.Parse()
+	flag.Usage = usage
+
+	command, args := splitCommand(os.Args[1:])
+	flag.CommandLine.Parse(args)
+	if command == "" && flag.NArg() > 0 {
+		// the command can also come after the flags
+		command = flag.Arg(0)
+		flag.CommandLine.Parse(flag.Args()[1:])
+	}
+	if flag.NArg() > 0 {
+		bail(fmt.Errorf("unexpected argument `%v`, see `alvu -h`", flag.Arg(0)))
+	}
 
 	// Show version and exit
 	if versionFlag {
@@ -168,6 +323,8 @@ func main() {
 	basePath = filepath.Join(*basePathFlag)
 	pagesPath := filepath.Join(*basePathFlag, "pages")
 	publicPath := filepath.Join(*basePathFlag, "public")
+	shortcodesPath := filepath.Join(*basePathFlag, "shortcodes")
+	dataPath := filepath.Join(*basePathFlag, "data")
 	headFilePath := filepath.Join(pagesPath, "_head.html")
 	baseFilePath := filepath.Join(pagesPath, "_layout.html")
 	tailFilePath := filepath.Join(pagesPath, "_tail.html")
@@ -176,13 +333,36 @@ func main() {
 	hooksPath := filepath.Join(*basePathFlag, *hooksPathFlag)
 	hardWraps = *hardWrapsFlag
 
+	switch command {
+	case "":
+	case "check":
+		os.Exit(runCheck(&Alvu{
+			pagesPath:  pagesPath,
+			dataPath:   dataPath,
+			configPath: findConfig(basePath, *configFlag),
+		}))
+	case "check-links":
+		// checked after the build
+	default:
+		bail(fmt.Errorf("unknown command `%v`, see `alvu -h`", command))
+	}
+
 	headTailDeprecationWarning := color.ColorString{}
 	headTailDeprecationWarning.Yellow(logPrefix).Yellow("[WARN] use of _tail.html and _head.html is deprecated, please use _layout.html instead")
 
+	configPath := findConfig(basePath, *configFlag)
+	config, err := LoadConfig(basePath, configPath)
+	bail(err)
+
 	os.MkdirAll(publicPath, os.ModePerm)
 
 	alvuApp := &Alvu{
-		publicPath: publicPath,
+		publicPath:     publicPath,
+		pagesPath:      pagesPath,
+		shortcodesPath: shortcodesPath,
+		dataPath:       dataPath,
+		configPath:     configPath,
+		config:         config,
 	}
 
 	watcher := NewWatcher(alvuApp, *pollDurationFlag)
@@ -190,13 +370,31 @@ func main() {
 	if *serveFlag {
 		watcher.AddDir(pagesPath)
 		watcher.AddDir(publicPath)
+		if _, err := os.Stat(shortcodesPath); err == nil {
+			watcher.AddDir(shortcodesPath)
+		}
+		if _, err := os.Stat(dataPath); err == nil {
+			watcher.AddDir(dataPath)
+		}
+		if configPath != "" {
+			watcher.AddDir(configPath)
+		}
+		for _, generator := range config.Generators {
+			templatePath := filepath.Join(basePath, filepath.FromSlash(generator.Template))
+			if _, err := os.Stat(templatePath); err == nil && !watcher.isWatched(templatePath) {
+				watcher.AddDir(templatePath)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(pagesPath, renderHooksDir)); err == nil {
+			watcher.AddDir(filepath.Join(pagesPath, renderHooksDir))
+		}
 	}
 
 	onDebug(func() {
 		debugInfo("Opening _head")
 		memuse()
 	})
-	_, err := os.Open(headFilePath)
+	_, err = os.Open(headFilePath)
 	if err != nil {
 		if err == fs.ErrNotExist {
 			log.Println("no _head.html found,skipping")
@@ -253,7 +451,7 @@ func main() {
 		log.Println(toProcess)
 	})
 
-	initMDProcessor(*enableHighlightingFlag, *highlightThemeFlag)
+	initMDProcessor(*enableHighlightingFlag, *highlightThemeFlag, config.Markdown)
 
 	onDebug(func() {
 		debugInfo("Running all OnStart hooks")
@@ -268,29 +466,14 @@ func main() {
 		debugInfo("Creating Alvu Files")
 		memuse()
 	})
-	headContent, _ := os.ReadFile(headFilePath)
-	tailContent, _ := os.ReadFile(tailFilePath)
-	baseTemplateData, _ := os.ReadFile(baseFilePath)
+	alvuApp.headContent, _ = os.ReadFile(headFilePath)
+	alvuApp.tailContent, _ = os.ReadFile(tailFilePath)
+	alvuApp.baseTemplateData, _ = os.ReadFile(baseFilePath)
 	for _, toProcessItem := range toProcess {
 		fileName := strings.Replace(toProcessItem, pagesPath, "", 1)
 		fileName = prefixSlashPath.ReplaceAllString(fileName, "")
-		destFilePath := strings.Replace(toProcessItem, pagesPath, outPath, 1)
-		isHTML := strings.HasSuffix(fileName, ".html")
-
-		alvuFile := &AlvuFile{
-			lock:             &sync.Mutex{},
-			sourcePath:       toProcessItem,
-			hooks:            hookCollection,
-			destPath:         destFilePath,
-			name:             fileName,
-			isHTML:           isHTML,
-			headContent:      headContent,
-			tailContent:      tailContent,
-			baseTemplateData: baseTemplateData,
-			data:             map[string]interface{}{},
-			extras:           map[string]interface{}{},
-		}
 
+		alvuFile := alvuApp.NewFile(toProcessItem, fileName)
 		alvuApp.AddFile(alvuFile)
 
 		// If serving, also add the nested path into it
@@ -299,7 +482,10 @@ func main() {
 		}
 	}
 
-	alvuApp.Build()
+	bail(alvuApp.Build())
+	if *serveFlag {
+		watcher.AddIncludes()
+	}
 
 	onDebug(func() {
 		runtime.GC()
@@ -310,12 +496,96 @@ func main() {
 	cs := &color.ColorString{}
 	fmt.Println(cs.Blue(logPrefix).Green("Compiled ").Cyan("\"" + basePath + "\"").Green(" to ").Cyan("\"" + outPath + "\"").String())
 
+	if command == "check-links" {
+		hookCollection.Shutdown()
+		alvuApp.shortcodes.Shutdown()
+		os.Exit(runCheckLinks(outPath, *externalLinksFlag))
+	}
+
 	if *serveFlag {
 		watcher.StartWatching()
 		runServer(*portFlag)
 	}
 
 	hookCollection.Shutdown()
+	alvuApp.shortcodes.Shutdown()
+}
+
+// splitCommand separates the optional command from the flags
+// when it's the first argument
+func splitCommand(args []string) (string, []string) {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		return args[0], args[1:]
+	}
+	return "", args
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage of alvu:\n  alvu [command] [flags]\n\n")
+	fmt.Fprintf(out, "Commands:\n")
+	fmt.Fprintf(out, "  check\n        validate the frontmatter of all pages against their schemas\n")
+	fmt.Fprintf(out, "  check-links\n        build the site and report broken links and anchors in the output\n\n")
+	fmt.Fprintf(out, "Flags:\n")
+	flag.PrintDefaults()
+}
+
+// runCheck validates all pages, generated ones included, without
+// building them and returns the exit code for the process
+func runCheck(alvuApp *Alvu) int {
+	config, err := LoadConfig(basePath, alvuApp.configPath)
+	if err != nil {
+		logError(err)
+		return 1
+	}
+	alvuApp.config = config
+
+	data, err := CollectData(alvuApp.dataPath)
+	if err != nil {
+		logError(err)
+		return 1
+	}
+	alvuApp.data = data
+
+	for _, toProcessItem := range CollectFilesToProcess(alvuApp.pagesPath) {
+		fileName, _ := filepath.Rel(alvuApp.pagesPath, toProcessItem)
+		alvuApp.AddFile(alvuApp.NewFile(toProcessItem, fileName))
+	}
+	if err := alvuApp.GeneratePages(); err != nil {
+		logError(err)
+		return 1
+	}
+
+	// every file is checked, the ones that can't
+	// be read are reported and left out
+	loaded := []*AlvuFile{}
+	for _, alvuFile := range alvuApp.files {
+		if err := alvuFile.Load(); err != nil {
+			logError(err)
+			continue
+		}
+		loaded = append(loaded, alvuFile)
+	}
+	invalid := len(alvuApp.files) - len(loaded)
+
+	if err := alvuApp.ApplyDefaults(loaded...); err != nil {
+		logError(err)
+		return 1
+	}
+	violations := alvuApp.ValidateMeta(loaded...)
+	if violations != nil {
+		logError(violations)
+	}
+	if invalid > 0 {
+		logError(fmt.Errorf("found %v file(s) with invalid frontmatter", invalid))
+	}
+	if invalid > 0 || violations != nil {
+		return 1
+	}
+
+	cs := &color.ColorString{}
+	fmt.Println(cs.Blue(logPrefix).Green("Checked ").Cyan(fmt.Sprint(len(alvuApp.files))).Green(" file(s), no issues found").String())
+	return 0
 }
 
 func runServer(port string) {
@@ -350,7 +620,7 @@ func CollectFilesToProcess(basepath string) []string {
 	for _, pathInfo := range pathstoprocess {
 		_path := filepath.Join(basepath, pathInfo.Name())
 
-		if Contains(layoutFiles, pathInfo.Name()) {
+		if isSpecialFile(pathInfo.Name()) {
 			continue
 		}
 
@@ -365,6 +635,15 @@ func CollectFilesToProcess(basepath string) []string {
 	return files
 }
 
+// isSpecialFile is true for files in `pages` that
+// configure the build instead of being built themselves
+func isSpecialFile(name string) bool {
+	return Contains(layoutFiles, name) ||
+		Contains(defaultsFileNames, name) ||
+		name == schemaFileName ||
+		name == renderHooksDir
+}
+
 func CollectHooks(basePath, hooksBasePath string) {
 	if _, err := os.Stat(hooksBasePath); err != nil {
 		return
@@ -391,35 +670,117 @@ func CollectHooks(basePath, hooksBasePath string) {
 
 }
 
-func initMDProcessor(highlight bool, theme string) {
+// mdSettings are the site wide settings every
+// markdown processor is created with
+var mdSettings struct {
+	highlight   bool
+	theme       string
+	config      MarkdownConfig
+	renderHooks *RenderHooks
+}
 
-	rendererOptions := []renderer.Option{
-		html.WithXHTML(),
-		html.WithUnsafe(),
-	}
+func initMDProcessor(highlight bool, theme string, config MarkdownConfig) {
+	mdSettings.highlight = highlight
+	mdSettings.theme = theme
+	mdSettings.config = config
+
+	mdProcessor = newMDProcessor(config.MarkdownOptions)
+}
+
+func newMDProcessor(options MarkdownOptions) goldmark.Markdown {
+	rendererOptions := []renderer.Option{}
 
-	if hardWraps {
+	if options.enabled(options.XHTML, true) {
+		rendererOptions = append(rendererOptions, html.WithXHTML())
+	}
+	if options.enabled(options.Unsafe, true) {
+		rendererOptions = append(rendererOptions, html.WithUnsafe())
+	}
+	if options.enabled(options.HardWraps, hardWraps) {
 		rendererOptions = append(rendererOptions, html.WithHardWraps())
 	}
+
+	parserOptions := []parser.Option{
+		parser.WithAutoHeadingID(),
+		parser.WithHeadingAttribute(),
+		parser.WithASTTransformers(
+			util.Prioritized(&linkResolver{}, 100),
+			util.Prioritized(&codeBlockAttributes{}, 100),
+			util.Prioritized(&summaryMarkerTransformer{}, 100),
+		),
+	}
+	if options.enabled(options.Attributes, false) {
+		parserOptions = append(parserOptions, parser.WithAttribute())
+	}
+
+	extensions := []goldmark.Extender{}
+	for _, ext := range []struct {
+		enabled   bool
+		extension goldmark.Extender
+	}{
+		{options.enabled(options.Tables, true), extension.Table},
+		{options.enabled(options.Strikethrough, true), extension.Strikethrough},
+		{options.enabled(options.Linkify, true), extension.Linkify},
+		{options.enabled(options.TaskList, true), extension.TaskList},
+		{options.enabled(options.Footnote, true), extension.Footnote},
+		{options.enabled(options.DefinitionList, false), extension.DefinitionList},
+		{options.enabled(options.Typographer, false), extension.Typographer},
+		{options.enabled(options.CJK, false), extension.CJK},
+		{true, newAlertExtension(mdSettings.config.Alerts)},
+		{true, &wikiLinkExtension{}},
+	} {
+		if ext.enabled {
+			extensions = append(extensions, ext.extension)
+		}
+	}
+
 	gmPlugins := []goldmark.Option{
-		goldmark.WithExtensions(extension.GFM, extension.Footnote),
+		goldmark.WithExtensions(extensions...),
 		goldmark.WithParserOptions(
-			parser.WithAutoHeadingID(),
+			parserOptions...,
 		),
 		goldmark.WithRendererOptions(
 			rendererOptions...,
 		),
 	}
 
-	if highlight {
-		gmPlugins = append(gmPlugins, goldmark.WithExtensions(
-			highlighting.NewHighlighting(
-				highlighting.WithStyle(theme),
+	anchors := mdSettings.config.Anchors
+	if anchors.Placement == "before" || anchors.Placement == "after" {
+		gmPlugins = append(gmPlugins, goldmark.WithRendererOptions(
+			renderer.WithNodeRenderers(
+				util.Prioritized(newHeadingAnchorRenderer(anchors), 100),
 			),
 		))
 	}
 
-	mdProcessor = goldmark.New(gmPlugins...)
+	var highlighter renderer.NodeRenderer
+	if mdSettings.highlight {
+		highlighter = newHighlightRenderer(mdSettings.config.Highlight)
+	}
+	gmPlugins = append(gmPlugins, goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(newCodeBlockRenderer(mdSettings.config.CodeBlocks, highlighter), 150),
+			util.Prioritized(&summaryMarkerRenderer{}, 150),
+		),
+	))
+
+	md := goldmark.New(gmPlugins...)
+	if mdSettings.renderHooks == nil {
+		return md
+	}
+
+	// the processor without the hooks renders the
+	// nodes the hooks don't handle
+	hooks := &renderHookRenderer{
+		hooks:    mdSettings.renderHooks,
+		fallback: md.Renderer(),
+	}
+	hooks.markdown = goldmark.New(append(gmPlugins, goldmark.WithRendererOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(hooks, 50),
+		),
+	))...)
+	return hooks.markdown
 }
 
 type Hook struct {
@@ -469,12 +830,43 @@ type AlvuFile struct {
 	targetName       []byte
 	data             map[string]interface{}
 	extras           map[string]interface{}
+	page             *PageData
+	alvu             *Alvu
+	// links to markdown files that aren't pages
+	brokenLinks []string
+	// files pulled in with the include and embed shortcodes
+	includes []string
+	// pages created by a generator are read from its template,
+	// with the meta the generator adds to the frontmatter
+	generator     *Generator
+	templatePath  string
+	generatedMeta map[string]interface{}
+	// content of the file for this build, rendered the first time
+	// it's written or another page uses its summary or headings
+	rendered *renderedContent
+	inRender bool
 }
 
-func (alvuFile *AlvuFile) Build() {
-	bail(alvuFile.ReadFile())
-	bail(alvuFile.ParseMeta())
+// Load reads the file and parses its frontmatter
+func (alvuFile *AlvuFile) Load() error {
+	if err := alvuFile.ReadFile(); err != nil {
+		return err
+	}
+	if err := alvuFile.ParseMeta(); err != nil {
+		return err
+	}
+	if len(alvuFile.generatedMeta) > 0 && alvuFile.meta == nil {
+		alvuFile.meta = map[string]interface{}{}
+	}
+	for key, value := range alvuFile.generatedMeta {
+		alvuFile.meta[key] = value
+	}
+	return nil
+}
 
+// RunHooks passes the file through the hooks, the file is
+// written once every file has been through them
+func (alvuFile *AlvuFile) RunHooks() {
 	if len(alvuFile.hooks) == 0 {
 		alvuFile.ProcessFile(nil)
 	}
@@ -493,12 +885,10 @@ func (alvuFile *AlvuFile) Build() {
 			bail(alvuFile.ProcessFile(hook.state))
 		}
 	}
-
-	alvuFile.FlushFile()
 }
 
 func (af *AlvuFile) ReadFile() error {
-	filecontent, err := os.ReadFile(af.sourcePath)
+	filecontent, err := os.ReadFile(af.templateOrSourcePath())
 	if err != nil {
 		return fmt.Errorf("error reading file, error: %v", err)
 	}
@@ -507,29 +897,40 @@ func (af *AlvuFile) ReadFile() error {
 }
 
 func (af *AlvuFile) ParseMeta() error {
-	sep := []byte("---")
-	if !bytes.HasPrefix(af.content, sep) {
-		af.writeableContent = af.content
+	fm, ok := splitFrontmatter(af.content)
+	if !ok || (fm.format == "yaml" && !isYAMLMapping(fm.raw)) {
+		// no frontmatter or just a horizontal rule at the
+		// start of the file
+		af.writeableContent = bytes.TrimPrefix(af.content, utf8BOM)
 		return nil
 	}
 
-	metaParts := bytes.SplitN(af.content, sep, 3)
-	if len(metaParts) < 3 {
-		return fmt.Errorf("malformed frontmatter: expected YAML block between '---' markers")
-	}
-
 	var meta map[string]interface{}
-	err := yaml.Unmarshal([]byte(metaParts[1]), &meta)
+	line, err := unmarshalData(fm.format, fm.raw, &meta)
 	if err != nil {
-		return err
+		return &FrontmatterError{
+			File: af.sourcePath,
+			Line: fm.line + max(line, 1) - 1,
+			Err:  err,
+		}
 	}
 
 	af.meta = meta
-	af.writeableContent = []byte(metaParts[2])
+	af.writeableContent = fm.body
 
 	return nil
 }
 
+// bodyLine is the line of the file the content starts at,
+// content that was replaced by a hook starts at the first line
+func (af *AlvuFile) bodyLine() int {
+	if !bytes.HasSuffix(af.content, af.writeableContent) {
+		return 1
+	}
+	prefix := af.content[:len(af.content)-len(af.writeableContent)]
+	return bytes.Count(prefix, []byte("\n")) + 1
+}
+
 func (af *AlvuFile) ProcessFile(hook *lua.LState) error {
 	// pre process hook => should return back json with `content` and `data`
 	af.lock.Lock()
@@ -542,12 +943,26 @@ func (af *AlvuFile) ProcessFile(hook *lua.LState) error {
 
 	buf := bytes.NewBuffer([]byte(""))
 	mdToHTML := ""
+	toc := []*TOCEntry{}
+	var summary template.HTML
 
 	if filepath.Ext(af.name) == ".md" {
 		newName := strings.Replace(af.name, filepath.Ext(af.name), ".html", 1)
 		af.targetName = []byte(newName)
-		mdProcessor.Convert(af.writeableContent, buf)
-		mdToHTML = buf.String()
+		md, err := af.markdownProcessor()
+		if err != nil {
+			return err
+		}
+		doc := parseMarkdown(md, af.writeableContent, af)
+		toc = extractTOC(doc, af.writeableContent, af.alvu.config.TOC)
+		md.Renderer().Render(buf, af.writeableContent, doc)
+
+		// without the template pass, same as the html
+		content, pageSummary, _, err := af.splitSummary(buf.Bytes())
+		if err != nil {
+			return err
+		}
+		mdToHTML, summary = string(content), pageSummary
 	}
 
 	if hook == nil {
@@ -559,15 +974,29 @@ func (af *AlvuFile) ProcessFile(hook *lua.LState) error {
 		SourcePath       string                 `json:"source_path"`
 		DestPath         string                 `json:"dest_path"`
 		Meta             map[string]interface{} `json:"meta"`
+		Title            string                 `json:"title"`
+		Weight           int                    `json:"weight"`
+		URL              string                 `json:"url"`
 		WriteableContent string                 `json:"content"`
 		HTMLContent      string                 `json:"html"`
+		TOC              []*TOCEntry            `json:"toc"`
+		Backlinks        []PageLink             `json:"backlinks"`
+		Summary          string                 `json:"summary"`
+		SiteData         map[string]interface{} `json:"site_data"`
 	}{
 		Name:             string(af.targetName),
 		SourcePath:       af.sourcePath,
 		DestPath:         af.destPath,
 		Meta:             af.meta,
+		Title:            af.page.Title,
+		Weight:           af.page.Weight,
+		URL:              af.page.URL,
 		WriteableContent: string(af.writeableContent),
 		HTMLContent:      mdToHTML,
+		TOC:              toc,
+		Backlinks:        pageLinks(af.page.Backlinks),
+		Summary:          string(summary),
+		SiteData:         af.alvu.data,
 	}
 
 	hookJsonInput, err := json.Marshal(hookInput)
@@ -613,16 +1042,8 @@ func (af *AlvuFile) FlushFile() {
 	destFolder := filepath.Dir(af.destPath)
 	os.MkdirAll(destFolder, os.ModePerm)
 
-	justFileName := strings.TrimSuffix(
-		filepath.Base(af.destPath),
-		filepath.Ext(af.destPath),
-	)
-
-	targetFile := strings.Replace(filepath.Join(af.destPath), af.name, string(af.targetName), 1)
-	if justFileName != "index" && justFileName != "404" {
-		targetFile = filepath.Join(filepath.Dir(af.destPath), justFileName, "index.html")
-		os.MkdirAll(filepath.Dir(targetFile), os.ModePerm)
-	}
+	targetFile := af.targetFilePath()
+	os.MkdirAll(filepath.Dir(targetFile), os.ModePerm)
 
 	onDebug(func() {
 		debugInfo("flushing for file: " + af.name + string(af.targetName))
@@ -648,35 +1069,18 @@ func (af *AlvuFile) FlushFile() {
 		writeHeadTail = true
 	}
 
-	if writeHeadTail && af.headContent != nil {
-		f.Write(af.headContent)
-	}
+	renderData := af.renderData()
 
-	renderData := PageRenderData{
-		Meta: SiteMeta{
-			BaseURL: baseurl,
-		},
-		Data:   af.data,
-		Extras: af.extras,
+	if writeHeadTail && af.headContent != nil {
+		bail(executeHTMLTemplate(f, "head", af.headContent, renderData))
 	}
 
-	// Run the Markdown file through the conversion
-	// process to be able to use template variables in
-	// the markdown instead of writing them in
-	// raw HTML
-	var preConvertHTML bytes.Buffer
-	preConvertTmpl := textTmpl.New("temporary_pre_template")
-	preConvertTmpl.Parse(string(af.writeableContent))
-	err = preConvertTmpl.Execute(&preConvertHTML, renderData)
-	bail(err)
-
-	var toHtml bytes.Buffer
-	if !af.isHTML {
-		err = mdProcessor.Convert(preConvertHTML.Bytes(), &toHtml)
-		bail(err)
-	} else {
-		toHtml = preConvertHTML
+	rendered := af.render()
+	bail(rendered.err)
+	for _, link := range af.brokenLinks {
+		warn(fmt.Sprintf("%v: link to missing page `%v`", af.sourcePath, link))
 	}
+	toHtml := *bytes.NewBuffer(rendered.html)
 
 	layoutData := LayoutRenderData{
 		PageRenderData: renderData,
@@ -705,23 +1109,171 @@ func (af *AlvuFile) FlushFile() {
 	)
 
 	if writeHeadTail && af.tailContent != nil && len(af.baseTemplateData) == 0 {
-		f.Write(af.tailContent)
+		bail(executeHTMLTemplate(f, "tail", af.tailContent, renderData))
 	}
+}
 
-	data, err := os.ReadFile(targetFile)
-	bail(err)
+// renderData is what the templates of the page are executed with
+func (af *AlvuFile) renderData() PageRenderData {
+	return PageRenderData{
+		Meta: SiteMeta{
+			BaseURL: baseurl,
+		},
+		Site: SiteData{
+			Nav:   buildNav(af.page.root(), af.page),
+			Menus: af.alvu.menus,
+			Data:  af.alvu.data,
+		},
+		Page:   af.page,
+		Data:   af.data,
+		Extras: af.extras,
+	}
+}
 
-	onDebug(func() {
-		debugInfo("template path: %v", af.sourcePath)
-	})
+// renderedContent is the content of a file after the shortcodes, the
+// template pass and the markdown, with the summary and headings of
+// markdown files taken from it
+type renderedContent struct {
+	html      []byte
+	toc       []*TOCEntry
+	summary   template.HTML
+	truncated bool
+	err       error
+}
 
-	t := template.New(filepath.Join(af.sourcePath))
-	t.Parse(string(data))
+// render renders the content of the file once per build, the pages
+// are rendered as they are written or when their summary or headings
+// are used by another page, whichever comes first
+func (af *AlvuFile) render() *renderedContent {
+	if af.rendered != nil {
+		return af.rendered
+	}
 
-	f.Seek(0, 0)
+	previous := af.alvu.rendering
+	af.alvu.rendering = af
+	af.inRender = true
+	defer func() {
+		af.alvu.rendering = previous
+		af.inRender = false
+	}()
 
-	err = t.Execute(f, renderData)
-	bail(err)
+	rendered := &renderedContent{}
+	af.includes = nil
+	rendered.html, rendered.toc, rendered.err = af.renderContent(af.writeableContent, af.renderData())
+	if rendered.err == nil && af.isMarkdown() {
+		rendered.html = tocPlaceholderPattern.ReplaceAll(rendered.html, []byte(renderTOC(rendered.toc)))
+		rendered.html, rendered.summary, rendered.truncated, rendered.err = af.splitSummary(rendered.html)
+	}
+	af.rendered = rendered
+	return rendered
+}
+
+// rendered is the rendered content of the page's file, nil for
+// sections without a file and pages in the middle of being rendered,
+// which is the case when pages use each other's summaries
+func (p *PageData) rendered() *renderedContent {
+	if p.file == nil || p.file.alvu == nil || p.file.inRender {
+		return nil
+	}
+	return p.file.render()
+}
+
+// renderContent runs content of the file through the conversion
+// process to be able to use template variables in the markdown
+// instead of writing them in raw HTML, fenced code and raw blocks
+// are kept out of it. The headings are returned for markdown files
+func (af *AlvuFile) renderContent(pageContent []byte, renderData PageRenderData) ([]byte, []*TOCEntry, error) {
+	verbatim := &verbatimStore{}
+	var shortcodes *shortcodeExpansion
+	var err error
+	if af.isTemplated() {
+		shortcodes, pageContent, err = af.alvu.shortcodes.Expand(af, pageContent, renderData, verbatim)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	pageContent = verbatim.Protect(pageContent, af.isMarkdown())
+
+	var preConvertHTML bytes.Buffer
+	if af.isTemplated() {
+		preConvertTmpl := textTmpl.New("temporary_pre_template")
+		_, err = preConvertTmpl.Parse(string(pageContent))
+		if err != nil {
+			return nil, nil, fmt.Errorf("%v: %v", af.sourcePath, err)
+		}
+		if err := preConvertTmpl.Execute(&preConvertHTML, renderData); err != nil {
+			return nil, nil, fmt.Errorf("%v: %v", af.sourcePath, err)
+		}
+	} else {
+		preConvertHTML.Write(pageContent)
+	}
+	source := verbatim.Restore(preConvertHTML.Bytes())
+
+	var toc []*TOCEntry
+	var toHtml bytes.Buffer
+	if !af.isHTML {
+		md, err := af.markdownProcessor()
+		if err != nil {
+			return nil, nil, err
+		}
+		af.brokenLinks = nil
+		doc := parseMarkdown(md, source, af)
+		toc = extractTOC(doc, source, af.alvu.config.TOC)
+		err = md.Renderer().Render(&toHtml, source, doc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%v: %v", af.sourcePath, err)
+		}
+	} else {
+		toHtml.Write(source)
+	}
+	if shortcodes != nil {
+		return shortcodes.RestoreHTML(toHtml.Bytes()), toc, nil
+	}
+	return toHtml.Bytes(), toc, nil
+}
+
+// targetFilePath is where the built file is written to, every file
+// other than index and 404 gets its own directory for pretty urls and
+// a section's _index is written as the index of its directory
+func (af *AlvuFile) targetFilePath() string {
+	justFileName := strings.TrimSuffix(
+		filepath.Base(af.destPath),
+		filepath.Ext(af.destPath),
+	)
+
+	switch justFileName {
+	case "index", "404":
+		return filepath.Join(filepath.Dir(af.destPath), filepath.Base(string(af.targetName)))
+	case "_index":
+		return filepath.Join(filepath.Dir(af.destPath), "index.html")
+	}
+	return filepath.Join(filepath.Dir(af.destPath), justFileName, "index.html")
+}
+
+// isMarkdown is true for files that go through the markdown processor
+func (af *AlvuFile) isMarkdown() bool {
+	return filepath.Ext(af.sourcePath) == ".md"
+}
+
+// isTemplated is false when the page has asked to skip
+// template evaluation with `raw: true` in the frontmatter
+func (af *AlvuFile) isTemplated() bool {
+	raw, _ := af.meta["raw"].(bool)
+	return !raw
+}
+
+// executeHTMLTemplate executes the given content as a template
+// with the passed data and writes it to the writer
+func executeHTMLTemplate(w io.Writer, name string, content []byte, data any) error {
+	onDebug(func() {
+		debugInfo("template path: %v", name)
+	})
+
+	t, err := template.New(name).Parse(string(content))
+	if err != nil {
+		return err
+	}
+	return t.Execute(w, data)
 }
 
 func NewHook() *lua.LState {
@@ -759,6 +1311,17 @@ func bail(err error) {
 	panic("")
 }
 
+// logError reports the error without stopping
+func logError(err error) {
+	cs := &color.ColorString{}
+	fmt.Fprintln(os.Stderr, cs.Red(logPrefix).Red(err.Error()).String())
+}
+
+func warn(msg string) {
+	cs := &color.ColorString{}
+	fmt.Fprintln(os.Stderr, cs.Yellow(logPrefix).Yellow("[WARN] "+msg).String())
+}
+
 func debugInfo(msg string, a ...any) {
 	cs := &color.ColorString{}
 	prefix := logPrefix
@@ -939,32 +1502,137 @@ func Contains(collection []string, item string) bool {
 // to be able to run alvu compile processes again
 // FIXME: redundant compile process for the files
 type Watcher struct {
-	alvu   *Alvu
-	poller *poller.Poller
-	dirs   []string
-	dirsMu sync.Mutex
+	alvu     *Alvu
+	interval int
+	poller   *poller.Poller
+	// stops the running poller, nil till it's started
+	stopPoller chan struct{}
+	dirs       []string
+	dirsMu     sync.Mutex
 }
 
 func NewWatcher(alvu *Alvu, interval int) *Watcher {
 	watcher := &Watcher{
-		alvu:   alvu,
-		poller: poller.NewPollWatcher(interval),
+		alvu:     alvu,
+		interval: interval,
+		poller:   poller.NewPollWatcher(interval),
 	}
 
 	return watcher
 }
 
-func (w *Watcher) AddDir(dirPath string) {
+// AddDir watches the dir, returns false if it was already watched.
+// A running poller can't be given new paths, they are picked up
+// when it's restarted
+func (w *Watcher) AddDir(dirPath string) bool {
 	w.dirsMu.Lock()
 	defer w.dirsMu.Unlock()
 	for _, pth := range w.dirs {
 		if pth == dirPath {
-			return
+			return false
 		}
 	}
 
 	w.dirs = append(w.dirs, dirPath)
-	w.poller.Add(dirPath)
+	if w.stopPoller == nil {
+		w.poller.Add(dirPath)
+	}
+	return true
+}
+
+// AddIncludes watches the files included by the pages that
+// aren't already in a watched directory, they can be anywhere
+// in the project
+func (w *Watcher) AddIncludes() {
+	added := false
+	for _, af := range w.alvu.files {
+		for _, include := range af.includes {
+			if _, err := os.Stat(include); err != nil || w.isWatched(include) {
+				continue
+			}
+			if w.AddDir(include) {
+				added = true
+			}
+		}
+	}
+	if added && w.stopPoller != nil {
+		w.restartPoller()
+	}
+}
+
+func (w *Watcher) startPoller() {
+	w.stopPoller = w.poller.StartPoller()
+}
+
+// restartPoller replaces the running poller with one that
+// watches all the dirs, has to be called from the goroutine
+// reading the events
+func (w *Watcher) restartPoller() {
+	close(w.stopPoller)
+	old := w.poller
+	go func() {
+		// a tick that's already running can still send an
+		// event, it's dropped instead of blocking the old poller
+		timeout := time.After(time.Duration(w.interval*2) * time.Millisecond)
+		for {
+			select {
+			case <-old.Events:
+			case <-timeout:
+				return
+			}
+		}
+	}()
+
+	w.dirsMu.Lock()
+	w.poller = poller.NewPollWatcher(w.interval)
+	for _, dirPath := range w.dirs {
+		w.poller.Add(dirPath)
+	}
+	w.dirsMu.Unlock()
+	w.startPoller()
+}
+
+func (w *Watcher) isWatched(filePath string) bool {
+	w.dirsMu.Lock()
+	defer w.dirsMu.Unlock()
+	for _, pth := range w.dirs {
+		rel, err := filepath.Rel(pth, filePath)
+		if err == nil && !strings.HasPrefix(rel, "..") {
+			return true
+		}
+	}
+	return false
+}
+
+// IncludedBy returns the files that include the file
+func (w *Watcher) IncludedBy(filePath string) []string {
+	files := []string{}
+	for _, af := range w.alvu.files {
+		for _, include := range af.includes {
+			if filepath.Clean(include) == filepath.Clean(filePath) {
+				files = append(files, af.sourcePath)
+				break
+			}
+		}
+	}
+	return files
+}
+
+func (w *Watcher) isPublicFile(filePath string) bool {
+	rel, err := filepath.Rel(w.alvu.publicPath, filePath)
+	return err == nil && !strings.HasPrefix(rel, "..")
+}
+
+// ReloadConfig reads the site config again, the one in
+// use is kept when the file has errors
+func (w *Watcher) ReloadConfig() {
+	config, err := LoadConfig(basePath, w.alvu.configPath)
+	if err != nil {
+		logError(err)
+		return
+	}
+	w.alvu.config = config
+	initMDProcessor(mdSettings.highlight, mdSettings.theme, config.Markdown)
 }
 
 func (w *Watcher) RebuildAlvu() {
@@ -972,7 +1640,10 @@ func (w *Watcher) RebuildAlvu() {
 		debugInfo("Rebuild Started")
 	})
 	w.alvu.CopyPublic()
-	w.alvu.Build()
+	// the output of the last build is kept till the errors are fixed
+	if err := w.alvu.Build(); err != nil {
+		logError(err)
+	}
 	onDebug(func() {
 		debugInfo("Build Completed")
 	})
@@ -982,12 +1653,29 @@ func (w *Watcher) RebuildFile(filePath string) {
 	onDebug(func() {
 		debugInfo("RebuildFile Started")
 	})
-	for i, af := range w.alvu.files {
+	for _, af := range w.alvu.files {
 		if af.sourcePath != filePath {
 			continue
 		}
 
-		w.alvu.files[i].Build()
+		// the file is left as it was till the errors are fixed
+		if err := af.Load(); err != nil {
+			logError(err)
+			break
+		}
+		if err := w.alvu.ApplyDefaults(af); err != nil {
+			logError(err)
+			break
+		}
+		if err := w.alvu.ValidateMeta(af); err != nil {
+			logError(err)
+			break
+		}
+		w.alvu.BuildPageTree()
+		w.alvu.menus = w.alvu.BuildMenus()
+		af.RunHooks()
+		af.rendered = nil
+		af.FlushFile()
 		break
 	}
 	onDebug(func() {
@@ -996,7 +1684,7 @@ func (w *Watcher) RebuildFile(filePath string) {
 }
 
 func (w *Watcher) StartWatching() {
-	go w.poller.StartPoller()
+	w.startPoller()
 	go func() {
 		for {
 			select {
@@ -1017,12 +1705,32 @@ func (w *Watcher) StartWatching() {
 
 				// If alvu file then just build the file, else
 				// just rebuilt the whole folder since it could
-				// be a file from the public folder or the _layout file
-				if w.alvu.IsAlvuFile(evt.Path) {
-					recompilingText := &color.ColorString{}
-					recompilingText.Blue(logPrefix).Cyan("Recompiling: ").Gray(evt.Path).Reset(" ")
-					fmt.Println(recompilingText.String())
-					w.RebuildFile(evt.Path)
+				// be a file from the public folder or the _layout file.
+				// Section indexes cascade into other files so they
+				// need the whole folder to be built as well
+				// the config is used by every page
+				if w.alvu.configPath != "" && filepath.Clean(evt.Path) == filepath.Clean(w.alvu.configPath) {
+					w.ReloadConfig()
+				}
+
+				includedBy := w.IncludedBy(evt.Path)
+				if w.alvu.IsAlvuFile(evt.Path) && filepath.Base(evt.Path) != sectionIndexName {
+					// pages embedding the page are built with it
+					for _, filePath := range append([]string{evt.Path}, includedBy...) {
+						recompilingText := &color.ColorString{}
+						recompilingText.Blue(logPrefix).Cyan("Recompiling: ").Gray(filePath).Reset(" ")
+						fmt.Println(recompilingText.String())
+						w.RebuildFile(filePath)
+					}
+				} else if len(includedBy) > 0 && !w.isPublicFile(evt.Path) {
+					// only the pages that include the file need a
+					// build, unless it also has to be copied over
+					for _, filePath := range includedBy {
+						recompilingText := &color.ColorString{}
+						recompilingText.Blue(logPrefix).Cyan("Recompiling: ").Gray(filePath).Reset(" ")
+						fmt.Println(recompilingText.String())
+						w.RebuildFile(filePath)
+					}
 				} else {
 					recompilingText := &color.ColorString{}
 					recompilingText.Blue(logPrefix).Cyan("Recompiling: ").Gray("All").Reset(" ")
@@ -1030,6 +1738,7 @@ func (w *Watcher) StartWatching() {
 					w.RebuildAlvu()
 				}
 
+				w.AddIncludes()
 				_clientNotifyReload()
 				fmt.Println(recompiledText.String())
 				continue
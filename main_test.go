@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcherAddIncludes(t *testing.T) {
+	dir := t.TempDir()
+	pagesPath := filepath.Join(dir, "pages")
+	if err := os.MkdirAll(pagesPath, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(pagesPath, "index.md"), []byte("# Home"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	af := &AlvuFile{sourcePath: filepath.Join(pagesPath, "index.md")}
+	w := NewWatcher(&Alvu{files: []*AlvuFile{af}}, 5)
+	w.AddDir(pagesPath)
+	w.startPoller()
+	defer func() { close(w.stopPoller) }()
+
+	// includes found after the poller started are watched
+	// without racing the running poller, run with -race
+	for i := 0; i < 5; i++ {
+		include := filepath.Join(dir, fmt.Sprintf("snippet-%v.md", i))
+		if err := os.WriteFile(include, []byte("snippet"), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		af.includes = append(af.includes, include)
+		w.AddIncludes()
+
+		modTime := time.Now().Add(time.Duration(i+1) * time.Hour)
+		if err := os.Chtimes(include, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		waitForEvent(t, w, include)
+	}
+
+	if w.AddDir(af.includes[0]) {
+		t.Errorf("AddDir(%v) = true for a watched file", af.includes[0])
+	}
+}
+
+func waitForEvent(t *testing.T, w *Watcher, filePath string) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case evt := <-w.poller.Events:
+			if evt.Path == filePath {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no event for %v", filePath)
+		}
+	}
+}
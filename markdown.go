@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// customHeadingIDPattern matches the `{#id}` attribute on headings
+var customHeadingIDPattern = regexp.MustCompile(`(?m)^ {0,3}#{1,6}[^\n]*\{[^}\n]*#([\w-]+)[^}\n]*\}[ \t]*$`)
+
+// htmlIDPattern matches ids in raw HTML that's part of the markdown
+var htmlIDPattern = regexp.MustCompile(`\sid=["']([^"']+)["']`)
+
+// AnchorConfig decides where the permalink
+// anchors are added to headings
+type AnchorConfig struct {
+	// `before` or `after` the heading text, anything else disables them
+	Placement string `json:"placement"`
+	Symbol    string `json:"symbol"`
+	Class     string `json:"class"`
+}
+
+// MarkdownConfig is the `markdown` key of the site config
+type MarkdownConfig struct {
+	MarkdownOptions
+	Anchors    AnchorConfig    `json:"anchors"`
+	Alerts     AlertConfig     `json:"alerts"`
+	Highlight  HighlightConfig `json:"highlight"`
+	CodeBlocks CodeBlockConfig `json:"code_blocks"`
+}
+
+// MarkdownOptions toggle the goldmark extensions and renderer
+// options, set in the site config and the `markdown` key of the
+// frontmatter. Options that aren't set keep their default
+type MarkdownOptions struct {
+	Tables         *bool `json:"tables"`
+	Strikethrough  *bool `json:"strikethrough"`
+	Linkify        *bool `json:"linkify"`
+	TaskList       *bool `json:"task_list"`
+	Footnote       *bool `json:"footnote"`
+	DefinitionList *bool `json:"definition_list"`
+	Typographer    *bool `json:"typographer"`
+	CJK            *bool `json:"cjk"`
+	Attributes     *bool `json:"attributes"`
+	Unsafe         *bool `json:"unsafe"`
+	XHTML          *bool `json:"xhtml"`
+	HardWraps      *bool `json:"hard_wraps"`
+}
+
+func (mo MarkdownOptions) enabled(option *bool, defaultValue bool) bool {
+	if option == nil {
+		return defaultValue
+	}
+	return *option
+}
+
+// merge returns the options with the ones set in `over` replacing them
+func (mo MarkdownOptions) merge(over MarkdownOptions) MarkdownOptions {
+	merged := reflect.ValueOf(&mo).Elem()
+	overValue := reflect.ValueOf(over)
+	for i := 0; i < overValue.NumField(); i++ {
+		if !overValue.Field(i).IsNil() {
+			merged.Field(i).Set(overValue.Field(i))
+		}
+	}
+	return mo
+}
+
+// key identifies the set of options to reuse processors
+func (mo MarkdownOptions) key() string {
+	var key strings.Builder
+	value := reflect.ValueOf(mo)
+	for i := 0; i < value.NumField(); i++ {
+		switch field := value.Field(i); {
+		case field.IsNil():
+			key.WriteByte('-')
+		case field.Elem().Bool():
+			key.WriteByte('1')
+		default:
+			key.WriteByte('0')
+		}
+	}
+	return key.String()
+}
+
+var mdProcessorsMu sync.Mutex
+var mdProcessors = map[string]goldmark.Markdown{}
+
+// setRenderHooks recreates the processors with the
+// render hooks, for when they've been reloaded
+func setRenderHooks(hooks *RenderHooks) {
+	mdProcessorsMu.Lock()
+	defer mdProcessorsMu.Unlock()
+	mdSettings.renderHooks = hooks
+	mdProcessors = map[string]goldmark.Markdown{}
+	mdProcessor = newMDProcessor(mdSettings.config.MarkdownOptions)
+}
+
+// markdownProcessor returns the processor for the file, pages
+// that change the options in their frontmatter get their own
+func (af *AlvuFile) markdownProcessor() (goldmark.Markdown, error) {
+	pageOptions, ok := af.meta["markdown"]
+	if !ok {
+		return mdProcessor, nil
+	}
+
+	var override MarkdownOptions
+	data, err := json.Marshal(pageOptions)
+	if err == nil {
+		err = json.Unmarshal(data, &override)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v: invalid `markdown` options, %v", af.sourcePath, err)
+	}
+
+	options := mdSettings.config.MarkdownOptions.merge(override)
+
+	mdProcessorsMu.Lock()
+	defer mdProcessorsMu.Unlock()
+	key := options.key()
+	if _, ok := mdProcessors[key]; !ok {
+		mdProcessors[key] = newMDProcessor(options)
+	}
+	return mdProcessors[key], nil
+}
+
+// parseMarkdown parses the source with the given processor and
+// a fresh set of heading ids for the page, links in the source are
+// resolved relative to the file when one is passed
+func parseMarkdown(md goldmark.Markdown, source []byte, af *AlvuFile) ast.Node {
+	ctx := parser.NewContext(parser.WithIDs(newHeadingIDs(source)))
+	ctx.Set(linkFileKey, af)
+	return md.Parser().Parse(text.NewReader(source), parser.WithContext(ctx))
+}
+
+// headingIDs generates the same ids as goldmark but knows about
+// the ids that are already taken by `{#id}` headings and raw HTML
+// in the source, so the generated ones stay unique in the page
+type headingIDs struct {
+	values map[string]bool
+}
+
+func newHeadingIDs(source []byte) *headingIDs {
+	ids := &headingIDs{
+		values: map[string]bool{},
+	}
+
+	// code samples don't end up as elements
+	var buf bytes.Buffer
+	last := 0
+	for _, fence := range fencedCodeRanges(source) {
+		buf.Write(source[last:fence[0]])
+		last = fence[1]
+	}
+	buf.Write(source[last:])
+	source = buf.Bytes()
+
+	for _, match := range customHeadingIDPattern.FindAllSubmatch(source, -1) {
+		ids.values[string(match[1])] = true
+	}
+	for _, match := range htmlIDPattern.FindAllSubmatch(source, -1) {
+		ids.values[string(match[1])] = true
+	}
+	return ids
+}
+
+func (s *headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	value = util.TrimLeftSpace(value)
+	value = util.TrimRightSpace(value)
+	result := []byte{}
+	for i := 0; i < len(value); {
+		v := value[i]
+		l := util.UTF8Len(v)
+		i += int(l)
+		if l != 1 {
+			continue
+		}
+		if util.IsAlphaNumeric(v) {
+			if 'A' <= v && v <= 'Z' {
+				v += 'a' - 'A'
+			}
+			result = append(result, v)
+		} else if util.IsSpace(v) || v == '-' || v == '_' {
+			result = append(result, '-')
+		}
+	}
+	if len(result) == 0 {
+		if kind == ast.KindHeading {
+			result = []byte("heading")
+		} else {
+			result = []byte("id")
+		}
+	}
+	if !s.values[string(result)] {
+		s.values[string(result)] = true
+		return result
+	}
+	for i := 1; ; i++ {
+		newResult := fmt.Sprintf("%s-%d", result, i)
+		if !s.values[newResult] {
+			s.values[newResult] = true
+			return []byte(newResult)
+		}
+	}
+}
+
+func (s *headingIDs) Put(value []byte) {
+	s.values[string(value)] = true
+}
+
+// headingAnchorRenderer renders headings the same way goldmark does
+// with a permalink to the heading's id added before or after the text
+type headingAnchorRenderer struct {
+	anchors AnchorConfig
+}
+
+func newHeadingAnchorRenderer(anchors AnchorConfig) renderer.NodeRenderer {
+	r := &headingAnchorRenderer{
+		anchors: anchors,
+	}
+	if r.anchors.Symbol == "" {
+		r.anchors.Symbol = "#"
+	}
+	if r.anchors.Class == "" {
+		r.anchors.Class = "anchor"
+	}
+	return r
+}
+
+func (r *headingAnchorRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindHeading, r.renderHeading)
+}
+
+func (r *headingAnchorRenderer) renderHeading(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*ast.Heading)
+	if entering {
+		_, _ = w.WriteString("<h")
+		_ = w.WriteByte("0123456"[n.Level])
+		if n.Attributes() != nil {
+			html.RenderAttributes(w, node, html.HeadingAttributeFilter)
+		}
+		_ = w.WriteByte('>')
+		if r.anchors.Placement == "before" {
+			r.writeAnchor(w, n)
+		}
+	} else {
+		if r.anchors.Placement == "after" {
+			r.writeAnchor(w, n)
+		}
+		_, _ = w.WriteString("</h")
+		_ = w.WriteByte("0123456"[n.Level])
+		_, _ = w.WriteString(">\n")
+	}
+	return ast.WalkContinue, nil
+}
+
+func (r *headingAnchorRenderer) writeAnchor(w util.BufWriter, n *ast.Heading) {
+	id, ok := n.AttributeString("id")
+	if !ok {
+		return
+	}
+	if r.anchors.Placement == "after" {
+		_ = w.WriteByte(' ')
+	}
+	_, _ = w.WriteString(`<a class="`)
+	_, _ = w.Write(util.EscapeHTML([]byte(r.anchors.Class)))
+	_, _ = w.WriteString(`" href="#`)
+	_, _ = w.Write(util.EscapeHTML([]byte(fmt.Sprintf("%s", id))))
+	_, _ = w.WriteString(`" aria-label="Permalink">`)
+	_, _ = w.Write(util.EscapeHTML([]byte(r.anchors.Symbol)))
+	_, _ = w.WriteString("</a>")
+	if r.anchors.Placement == "before" {
+		_ = w.WriteByte(' ')
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var externalURLPattern = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*:|//)`)
+
+// MenuEntry is an item of a named menu, defined either in
+// the site config or with `menu` in the frontmatter of a page
+type MenuEntry struct {
+	Identifier string       `json:"identifier"`
+	Name       string       `json:"name"`
+	URL        string       `json:"url"`
+	Page       string       `json:"page"`
+	Weight     int          `json:"weight"`
+	Parent     string       `json:"parent"`
+	Children   []*MenuEntry `json:"children"`
+	External   bool         `json:"-"`
+
+	// weight was set, `weight: 0` still orders the entry
+	weighted bool
+}
+
+// UnmarshalJSON keeps track of the weight being set
+func (me *MenuEntry) UnmarshalJSON(data []byte) error {
+	type menuEntry MenuEntry
+	if err := json.Unmarshal(data, (*menuEntry)(me)); err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	_, me.weighted = fields["weight"]
+	return nil
+}
+
+// IsActive is true if the entry links to the given page
+func (me *MenuEntry) IsActive(page *PageData) bool {
+	return page != nil && !me.External && me.URL != "" && me.URL == page.URL
+}
+
+// HasChild is true if any entry nested in this one
+// links to the given page
+func (me *MenuEntry) HasChild(page *PageData) bool {
+	for _, child := range me.Children {
+		if child.IsActive(page) || child.HasChild(page) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasChildren is true if there's entries nested in this one
+func (me *MenuEntry) HasChildren() bool {
+	return len(me.Children) > 0
+}
+
+// Menus maps the menu names to their top level entries
+type Menus map[string][]*MenuEntry
+
+// BuildMenus merges the menus from the config with the
+// ones declared by pages and nests entries under their parents
+func (al *Alvu) BuildMenus() Menus {
+	flat := map[string][]*MenuEntry{}
+
+	for name, entries := range al.config.Menus {
+		for _, entry := range entries {
+			flat[name] = append(flat[name], al.flattenMenuEntry(entry, "")...)
+		}
+	}
+
+	// pages already in a menu through the config aren't added again,
+	// their identifier still works as a parent for other entries
+	aliases := map[string]map[string]*MenuEntry{}
+	for _, af := range al.files {
+		for name, entry := range menuEntriesFromMeta(af.page) {
+			if existing := findMenuEntry(flat[name], entry); existing != nil {
+				if aliases[name] == nil {
+					aliases[name] = map[string]*MenuEntry{}
+				}
+				aliases[name][entry.Identifier] = existing
+				continue
+			}
+			flat[name] = append(flat[name], entry)
+		}
+	}
+
+	menus := Menus{}
+	for name, entries := range flat {
+		byIdentifier := map[string]*MenuEntry{}
+		for identifier, entry := range aliases[name] {
+			byIdentifier[identifier] = entry
+		}
+		for _, entry := range entries {
+			byIdentifier[entry.Identifier] = entry
+		}
+
+		// entries moved to the top level, they end the parent chains
+		topLevel := map[*MenuEntry]bool{}
+		for _, entry := range entries {
+			if entry.Parent == "" {
+				menus[name] = append(menus[name], entry)
+				topLevel[entry] = true
+				continue
+			}
+			parent, ok := byIdentifier[entry.Parent]
+			if !ok {
+				warn(fmt.Sprintf("menu %q: parent %q of %q not found", name, entry.Parent, entry.Identifier))
+				menus[name] = append(menus[name], entry)
+				topLevel[entry] = true
+				continue
+			}
+			if isMenuAncestor(entry, parent, byIdentifier, topLevel) {
+				warn(fmt.Sprintf("menu %q: %q is nested in itself through its parent %q", name, entry.Identifier, entry.Parent))
+				menus[name] = append(menus[name], entry)
+				topLevel[entry] = true
+				continue
+			}
+			parent.Children = append(parent.Children, entry)
+		}
+
+		sortMenuEntries(menus[name])
+	}
+
+	return menus
+}
+
+// findMenuEntry returns the entry with the same identifier
+// or linking to the same page, nil if there's none
+func findMenuEntry(entries []*MenuEntry, entry *MenuEntry) *MenuEntry {
+	for _, existing := range entries {
+		if existing.Identifier == entry.Identifier {
+			return existing
+		}
+		if entry.URL != "" && !existing.External && existing.URL == entry.URL {
+			return existing
+		}
+	}
+	return nil
+}
+
+// isMenuAncestor is true if the entry is one of the parents
+// of the given parent, which would nest it in itself
+func isMenuAncestor(entry *MenuEntry, parent *MenuEntry, byIdentifier map[string]*MenuEntry, topLevel map[*MenuEntry]bool) bool {
+	seen := map[*MenuEntry]bool{}
+	for parent != nil && !seen[parent] {
+		if parent == entry {
+			return true
+		}
+		if topLevel[parent] || parent.Parent == "" {
+			return false
+		}
+		seen[parent] = true
+		parent = byIdentifier[parent.Parent]
+	}
+	return false
+}
+
+// flattenMenuEntry resolves the url of the entry and moves the nested
+// children next to it so pages can use them as parents as well
+func (al *Alvu) flattenMenuEntry(configEntry *MenuEntry, parent string) []*MenuEntry {
+	// the config is kept as is for the next build
+	entry := &MenuEntry{}
+	*entry = *configEntry
+
+	if entry.Page != "" {
+		pagePath := filepath.Join(al.pagesPath, entry.Page)
+		for _, af := range al.files {
+			if af.sourcePath == pagePath {
+				entry.URL = af.page.URL
+				if entry.Name == "" {
+					entry.Name = af.page.Title
+				}
+				break
+			}
+		}
+		if entry.URL == "" {
+			warn(fmt.Sprintf("menu entry %q links to a missing page %q", entry.Name, entry.Page))
+		}
+	} else {
+		entry.URL, entry.External = resolveMenuURL(entry.URL)
+	}
+
+	if entry.Identifier == "" {
+		entry.Identifier = entry.Name
+	}
+	if entry.Parent == "" {
+		entry.Parent = parent
+	}
+
+	children := entry.Children
+	entry.Children = nil
+
+	flat := []*MenuEntry{entry}
+	for _, child := range children {
+		flat = append(flat, al.flattenMenuEntry(child, entry.Identifier)...)
+	}
+	return flat
+}
+
+// menuEntriesFromMeta reads the `menu` key of the frontmatter, which can
+// be the name of a menu, a list of names or a map of names to entry options
+//
+//	menu: main
+//	menu: [main, footer]
+//	menu:
+//	  main:
+//	    name: Docs
+//	    weight: 2
+//	    parent: guides
+func menuEntriesFromMeta(page *PageData) map[string]*MenuEntry {
+	entries := map[string]*MenuEntry{}
+
+	newEntry := func() *MenuEntry {
+		return &MenuEntry{
+			Identifier: page.URL,
+			Name:       page.Title,
+			URL:        page.URL,
+			Weight:     page.Weight,
+			weighted:   page.weighted,
+		}
+	}
+
+	switch menu := page.Meta["menu"].(type) {
+	case string:
+		entries[menu] = newEntry()
+	case []interface{}:
+		for _, name := range menu {
+			entries[fmt.Sprint(name)] = newEntry()
+		}
+	case map[string]interface{}:
+		for name, options := range menu {
+			entry := newEntry()
+			if opts, ok := options.(map[string]interface{}); ok {
+				if v, ok := opts["name"].(string); ok {
+					entry.Name = v
+				}
+				if v, ok := opts["identifier"].(string); ok {
+					entry.Identifier = v
+				}
+				if v, ok := opts["parent"].(string); ok {
+					entry.Parent = v
+				}
+				if v, ok := toFloat(opts["weight"]); ok {
+					entry.Weight, entry.weighted = int(v), true
+				}
+			}
+			entries[name] = entry
+		}
+	}
+
+	return entries
+}
+
+// resolveMenuURL prefixes site relative urls with the baseurl,
+// entries without a url don't link anywhere
+func resolveMenuURL(url string) (string, bool) {
+	if url == "" {
+		return "", false
+	}
+	if externalURLPattern.MatchString(url) {
+		return url, true
+	}
+	if strings.HasPrefix(url, "#") {
+		return url, false
+	}
+	return strings.TrimSuffix(baseurl, "/") + "/" + strings.TrimPrefix(url, "/"), false
+}
+
+// sortMenuEntries orders entries by weight, entries
+// without one go last, and then by name
+func sortMenuEntries(entries []*MenuEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.weighted != b.weighted {
+			return a.weighted
+		}
+		if a.Weight != b.Weight {
+			return a.Weight < b.Weight
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+	for _, entry := range entries {
+		sortMenuEntries(entry.Children)
+	}
+}
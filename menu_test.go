@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// describeMenu writes the entries as `Name(url)`, with the
+// children in brackets after their parent
+func describeMenu(entries []*MenuEntry) string {
+	described := []string{}
+	for _, entry := range entries {
+		item := entry.Name + "(" + entry.URL + ")"
+		if entry.HasChildren() {
+			item += " [" + describeMenu(entry.Children) + "]"
+		}
+		described = append(described, item)
+	}
+	return strings.Join(described, ", ")
+}
+
+func TestBuildMenus(t *testing.T) {
+	baseurl = "/site/"
+	defer func() { baseurl = "" }()
+
+	tests := []struct {
+		name   string
+		config string
+		// frontmatter `menu` of the pages, keyed by their url
+		pages map[string]interface{}
+		want  string
+	}{
+		{
+			name:   "urls",
+			config: `{"main": [{"name": "Blog", "url": "/blog/"}, {"name": "GitHub", "url": "https://github.com"}, {"name": "Top", "url": "#top"}]}`,
+			want:   "Blog(/site/blog/), GitHub(https://github.com), Top(#top)",
+		},
+		{
+			name:   "entry without a url",
+			config: `{"main": [{"name": "Guides", "children": [{"name": "Blog", "url": "/blog/"}]}]}`,
+			want:   "Guides() [Blog(/site/blog/)]",
+		},
+		{
+			name:   "linked page",
+			config: `{"main": [{"page": "install.md"}, {"name": "Missing", "page": "missing.md"}]}`,
+			want:   "Install(/site/install/), Missing()",
+		},
+		{
+			name:  "frontmatter",
+			pages: map[string]interface{}{"/site/install/": "main", "/site/usage/": []interface{}{"main"}},
+			want:  "Install(/site/install/), Usage(/site/usage/)",
+		},
+		{
+			name:   "frontmatter options",
+			config: `{"main": [{"name": "Guides", "identifier": "guides"}]}`,
+			pages: map[string]interface{}{
+				"/site/install/": map[string]interface{}{"main": map[string]interface{}{"name": "Setup", "parent": "guides"}},
+			},
+			want: "Guides() [Setup(/site/install/)]",
+		},
+		{
+			name:   "page in the config and the frontmatter",
+			config: `{"main": [{"name": "Get Started", "page": "install.md"}]}`,
+			pages:  map[string]interface{}{"/site/install/": "main"},
+			want:   "Get Started(/site/install/)",
+		},
+		{
+			name:   "parent through the frontmatter identifier of a page in the config",
+			config: `{"main": [{"page": "install.md"}]}`,
+			pages: map[string]interface{}{
+				"/site/install/": "main",
+				"/site/usage/":   map[string]interface{}{"main": map[string]interface{}{"parent": "/site/install/"}},
+			},
+			want: "Install(/site/install/) [Usage(/site/usage/)]",
+		},
+		{
+			name:   "same identifier",
+			config: `{"main": [{"name": "Install", "identifier": "install", "url": "/setup/"}]}`,
+			pages: map[string]interface{}{
+				"/site/install/": map[string]interface{}{"main": map[string]interface{}{"identifier": "install"}},
+			},
+			want: "Install(/site/setup/)",
+		},
+		{
+			name:   "weights",
+			config: `{"main": [{"name": "b"}, {"name": "c", "weight": 2}, {"name": "a"}, {"name": "d", "weight": 0}, {"name": "e", "weight": -1}]}`,
+			want:   "e(), d(), c(), a(), b()",
+		},
+		{
+			name:   "missing parent",
+			config: `{"main": [{"name": "a", "parent": "nope"}]}`,
+			want:   "a()",
+		},
+		{
+			name:   "parent cycle",
+			config: `{"main": [{"name": "a", "parent": "b"}, {"name": "b", "parent": "a"}]}`,
+			want:   "a() [b()]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &SiteConfig{}
+			if tt.config != "" {
+				if err := json.Unmarshal([]byte(tt.config), &config.Menus); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			al := &Alvu{pagesPath: "pages", config: config}
+			for _, name := range []string{"Install", "Usage"} {
+				page := &PageData{
+					Title: name,
+					URL:   "/site/" + strings.ToLower(name) + "/",
+					Meta:  map[string]interface{}{},
+				}
+				if menu, ok := tt.pages[page.URL]; ok {
+					page.Meta["menu"] = menu
+				}
+				al.files = append(al.files, &AlvuFile{sourcePath: "pages/" + strings.ToLower(name) + ".md", page: page})
+			}
+
+			if got := describeMenu(al.BuildMenus()["main"]); got != tt.want {
+				t.Errorf("BuildMenus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMenuEntryIsActive(t *testing.T) {
+	home := &PageData{URL: "/"}
+	tests := []struct {
+		name  string
+		entry *MenuEntry
+		want  bool
+	}{
+		{"same url", &MenuEntry{URL: "/"}, true},
+		{"other url", &MenuEntry{URL: "/blog/"}, false},
+		{"no url", &MenuEntry{}, false},
+		{"external", &MenuEntry{URL: "/", External: true}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.entry.IsActive(home); got != tt.want {
+				t.Errorf("IsActive(%v) = %v, want %v", home.URL, got, tt.want)
+			}
+		})
+	}
+}
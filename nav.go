@@ -0,0 +1,69 @@
+package main
+
+// NavItem is an entry in the navigation tree
+// that's built from the pages directory
+type NavItem struct {
+	Title     string
+	URL       string
+	Weight    int
+	IsSection bool
+	// the page being rendered
+	Active bool
+	// the page being rendered is this item or nested in it
+	InActiveTrail bool
+	Children      []*NavItem
+}
+
+// SiteData is the information about the whole site,
+// available to templates as `.Site`
+type SiteData struct {
+	Nav   []*NavItem
+	Menus Menus
+	// files from the data directory
+	Data map[string]interface{}
+}
+
+// buildNav creates the navigation tree for the section with the
+// active flags set for the current page. The frontmatter can change
+// the label with `nav_title` or leave a page out with `nav_hidden`
+func buildNav(section *PageData, current *PageData) []*NavItem {
+	items := []*NavItem{}
+	for _, entry := range section.entries() {
+		if hidden, _ := entry.Meta["nav_hidden"].(bool); hidden {
+			continue
+		}
+
+		item := &NavItem{
+			Title:     entry.Title,
+			URL:       entry.URL,
+			Weight:    entry.Weight,
+			IsSection: entry.IsSection,
+			Active:    entry == current,
+		}
+		if title, ok := entry.Meta["nav_title"].(string); ok {
+			item.Title = title
+		}
+
+		item.InActiveTrail = item.Active
+		if entry.IsSection {
+			item.Children = buildNav(entry, current)
+			for _, child := range item.Children {
+				if child.InActiveTrail {
+					item.InActiveTrail = true
+				}
+			}
+		}
+
+		items = append(items, item)
+	}
+	return items
+}
+
+// root returns the root section the page belongs to
+func (p *PageData) root() *PageData {
+	root := p
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	return root
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// describeNav writes the items as `Title`, with `*` for the active
+// item, `+` for the ones in the active trail and the children in brackets
+func describeNav(items []*NavItem) string {
+	described := []string{}
+	for _, item := range items {
+		text := item.Title
+		if item.Active {
+			text += "*"
+		} else if item.InActiveTrail {
+			text += "+"
+		}
+		if len(item.Children) > 0 {
+			text += " [" + describeNav(item.Children) + "]"
+		}
+		described = append(described, text)
+	}
+	return strings.Join(described, ", ")
+}
+
+func TestBuildNav(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		meta  map[string]map[string]interface{}
+		// file the nav is built for
+		current string
+		want    string
+	}{
+		{
+			name:    "flat",
+			files:   []string{"02-usage.md", "01-install.md", "404.html", "styles.css"},
+			current: "01-install.md",
+			want:    "Install*, Usage",
+		},
+		{
+			name:    "sections",
+			files:   []string{"index.md", "02-guides/_index.md", "02-guides/02-deploy.md", "02-guides/01-setup.md", "01-intro.md"},
+			current: "02-guides/02-deploy.md",
+			want:    "Intro, Guides+ [Setup, Deploy*], Home",
+		},
+		{
+			name:    "active section",
+			files:   []string{"guides/_index.md", "guides/setup.md"},
+			current: "guides/_index.md",
+			want:    "Guides* [Setup]",
+		},
+		{
+			name:  "nav title and hidden pages",
+			files: []string{"index.md", "about.md", "secret.md", "guides/_index.md", "guides/setup.md"},
+			meta: map[string]map[string]interface{}{
+				"index.md":         {"nav_title": "..", "weight": -1},
+				"secret.md":        {"nav_hidden": true},
+				"guides/_index.md": {"nav_hidden": true},
+			},
+			current: "about.md",
+			want:    ".., About*",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			al := &Alvu{pagesPath: "pages"}
+			var current *AlvuFile
+			for _, name := range tt.files {
+				af := al.NewFile("pages/"+name, name)
+				af.meta = map[string]interface{}{}
+				for key, value := range tt.meta[name] {
+					af.meta[key] = value
+				}
+				al.AddFile(af)
+				if name == tt.current {
+					current = af
+				}
+			}
+			al.BuildPageTree()
+
+			if got := describeNav(buildNav(al.root, current.page)); got != tt.want {
+				t.Errorf("buildNav() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
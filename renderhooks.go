@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// renderHooksDir is the directory in `pages` with the templates
+// that replace how some of the markdown nodes are rendered
+const renderHooksDir = "_render"
+
+// RenderHooks are the templates from the `_render` directory,
+// `link.html`, `image.html`, `heading.html`, `codeblock.html` and
+// `codeblock-<lang>.html` for code blocks of a single language
+type RenderHooks struct {
+	link       *template.Template
+	image      *template.Template
+	heading    *template.Template
+	codeblock  *template.Template
+	codeblocks map[string]*template.Template
+}
+
+// RenderHookData is what render hook templates are executed with
+type RenderHookData struct {
+	// url of links and images
+	Destination string
+	Title       string
+	// rendered content of links and headings, alt text of
+	// images and the escaped code of code blocks
+	Text template.HTML
+	// same as Text without any markup
+	PlainText string
+	// language of code blocks
+	Lang string
+	// level and id of headings
+	Level int
+	ID    string
+	// attributes of the node, for code blocks the
+	// ones in the info string, `go {linenos=true}`
+	Attributes map[string]any
+}
+
+// CollectRenderHooks loads the render hook templates, nil is
+// returned when the site doesn't have any
+func CollectRenderHooks(pagesPath string) (*RenderHooks, error) {
+	hooksPath := filepath.Join(pagesPath, renderHooksDir)
+	entries, err := os.ReadDir(hooksPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hooks := &RenderHooks{
+		codeblocks: map[string]*template.Template{},
+	}
+	found := false
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".html" {
+			continue
+		}
+
+		hookPath := filepath.Join(hooksPath, entry.Name())
+		data, err := os.ReadFile(hookPath)
+		if err != nil {
+			return nil, err
+		}
+		// the newline at the end of the file would end up
+		// in the middle of paragraphs for links and images
+		tmpl, err := template.New(entry.Name()).Funcs(templateFuncs).Parse(strings.TrimRight(string(data), "\r\n"))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", hookPath, err)
+		}
+
+		name := strings.TrimSuffix(entry.Name(), ".html")
+		switch {
+		case name == "link":
+			hooks.link = tmpl
+		case name == "image":
+			hooks.image = tmpl
+		case name == "heading":
+			hooks.heading = tmpl
+		case name == "codeblock":
+			hooks.codeblock = tmpl
+		case strings.HasPrefix(name, "codeblock-"):
+			hooks.codeblocks[strings.TrimPrefix(name, "codeblock-")] = tmpl
+		default:
+			warn(fmt.Sprintf("%v: unknown render hook, skipping", hookPath))
+			continue
+		}
+		found = true
+	}
+
+	if !found {
+		return nil, nil
+	}
+	return hooks, nil
+}
+
+// renderHookRenderer renders the nodes that have a hook with their
+// template, code blocks in languages without a hook are left
+// to the fallback renderer
+type renderHookRenderer struct {
+	hooks *RenderHooks
+	// renders the content of links and images, with the hooks
+	markdown goldmark.Markdown
+	fallback renderer.Renderer
+}
+
+func (r *renderHookRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	if r.hooks.link != nil {
+		reg.Register(ast.KindLink, r.renderLink)
+		reg.Register(ast.KindAutoLink, r.renderAutoLink)
+	}
+	if r.hooks.image != nil {
+		reg.Register(ast.KindImage, r.renderImage)
+	}
+	if r.hooks.heading != nil {
+		reg.Register(ast.KindHeading, r.renderHeading)
+	}
+	if r.hooks.codeblock != nil || len(r.hooks.codeblocks) > 0 {
+		reg.Register(ast.KindFencedCodeBlock, r.renderCodeBlock)
+	}
+}
+
+func (r *renderHookRenderer) renderLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.Link)
+
+	content, err := r.renderChildren(source, n)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+
+	return ast.WalkSkipChildren, r.execute(w, r.hooks.link, &RenderHookData{
+		Destination: string(n.Destination),
+		Title:       string(n.Title),
+		Text:        template.HTML(content),
+		PlainText:   nodeText(n, source),
+		Attributes:  nodeAttributes(n),
+	})
+}
+
+func (r *renderHookRenderer) renderAutoLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.AutoLink)
+
+	destination := string(n.URL(source))
+	label := string(n.Label(source))
+	if n.AutoLinkType == ast.AutoLinkEmail && !strings.HasPrefix(strings.ToLower(destination), "mailto:") {
+		destination = "mailto:" + destination
+	}
+
+	return ast.WalkSkipChildren, r.execute(w, r.hooks.link, &RenderHookData{
+		Destination: destination,
+		Text:        template.HTML(html.EscapeString(label)),
+		PlainText:   label,
+		Attributes:  nodeAttributes(n),
+	})
+}
+
+func (r *renderHookRenderer) renderImage(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.Image)
+
+	alt := nodeText(n, source)
+	return ast.WalkSkipChildren, r.execute(w, r.hooks.image, &RenderHookData{
+		Destination: string(n.Destination),
+		Title:       string(n.Title),
+		Text:        template.HTML(html.EscapeString(alt)),
+		PlainText:   alt,
+		Attributes:  nodeAttributes(n),
+	})
+}
+
+func (r *renderHookRenderer) renderHeading(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.Heading)
+
+	content, err := r.renderChildren(source, n)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+
+	id, _ := n.AttributeString("id")
+	idValue, _ := attributeValue(id).(string)
+	err = r.execute(w, r.hooks.heading, &RenderHookData{
+		Text:       template.HTML(content),
+		PlainText:  nodeText(n, source),
+		Level:      n.Level,
+		ID:         idValue,
+		Attributes: nodeAttributes(n),
+	})
+	_ = w.WriteByte('\n')
+	return ast.WalkSkipChildren, err
+}
+
+func (r *renderHookRenderer) renderCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*ast.FencedCodeBlock)
+
+	lang := string(n.Language(source))
+	hook, ok := r.hooks.codeblocks[lang]
+	if !ok {
+		hook = r.hooks.codeblock
+	}
+	if hook == nil {
+		return ast.WalkSkipChildren, r.fallback.Render(w, source, n)
+	}
+
+	var code strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		code.Write(line.Value(source))
+	}
+
+	err := r.execute(w, hook, &RenderHookData{
+		Text:       template.HTML(html.EscapeString(code.String())),
+		PlainText:  code.String(),
+		Lang:       lang,
+		Attributes: nodeAttributes(n),
+	})
+	_ = w.WriteByte('\n')
+	return ast.WalkSkipChildren, err
+}
+
+func (r *renderHookRenderer) renderChildren(source []byte, n ast.Node) (string, error) {
+	var buf bytes.Buffer
+	for child := n.FirstChild(); child != nil; child = child.NextSibling() {
+		if err := r.markdown.Renderer().Render(&buf, source, child); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+func (r *renderHookRenderer) execute(w util.BufWriter, hook *template.Template, data *RenderHookData) error {
+	if err := hook.Execute(w, data); err != nil {
+		return fmt.Errorf("render hook %v", err)
+	}
+	return nil
+}
+
+func nodeAttributes(n ast.Node) map[string]any {
+	attributes := map[string]any{}
+	for _, attr := range n.Attributes() {
+		attributes[string(attr.Name)] = attributeValue(attr.Value)
+	}
+	return attributes
+}
+
+// attributeValue converts the byte slices goldmark
+// keeps attribute values as into strings
+func attributeValue(value any) any {
+	switch v := value.(type) {
+	case []byte:
+		return string(v)
+	case []any:
+		values := make([]any, len(v))
+		for i := range v {
+			values[i] = attributeValue(v[i])
+		}
+		return values
+	}
+	return value
+}
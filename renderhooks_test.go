@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderHooks(t *testing.T) {
+	initMDProcessor(false, "", MarkdownConfig{})
+	defer setRenderHooks(nil)
+
+	tests := []struct {
+		name    string
+		hooks   map[string]string
+		content string
+		want    string
+	}{
+		{
+			name:    "link",
+			hooks:   map[string]string{"link.html": "<a href=\"{{ .Destination }}\" title=\"{{ .Title }}\">{{ .Text }} ({{ .PlainText }})</a>\n"},
+			content: "a [**b** c](https://x.dev \"T\") d",
+			want:    "<p>a <a href=\"https://x.dev\" title=\"T\"><strong>b</strong> c (b c)</a> d</p>\n",
+		},
+		{
+			name:    "autolink",
+			hooks:   map[string]string{"link.html": "[{{ .Destination }}|{{ .Text }}]"},
+			content: "<me@x.dev> <https://x.dev>",
+			want:    "<p>[mailto:me@x.dev|me@x.dev] [https://x.dev|https://x.dev]</p>\n",
+		},
+		{
+			name:    "image",
+			hooks:   map[string]string{"image.html": "<figure><img src=\"{{ .Destination }}\" alt=\"{{ .PlainText }}\"></figure>"},
+			content: "![a *cat*](cat.png)",
+			want:    "<p><figure><img src=\"cat.png\" alt=\"a cat\"></figure></p>\n",
+		},
+		{
+			name:    "heading",
+			hooks:   map[string]string{"heading.html": "<h{{ .Level }} id=\"{{ .ID }}\">{{ .Text }}</h{{ .Level }}>"},
+			content: "## Hello *there* {#hi}",
+			want:    "<h2 id=\"hi\">Hello <em>there</em></h2>\n",
+		},
+		{
+			name: "code block for a language",
+			hooks: map[string]string{
+				"codeblock.html":         "<pre data-lang=\"{{ .Lang }}\">{{ .Text }}</pre>",
+				"codeblock-mermaid.html": "<div class=\"mermaid\">{{ .PlainText }}</div>",
+			},
+			content: "```mermaid\na --> b\n```\n\n```go {linenos=true}\n<x>\n```",
+			want:    "<div class=\"mermaid\">a --&gt; b\n</div>\n<pre data-lang=\"go\">&lt;x&gt;\n</pre>\n",
+		},
+		{
+			name:    "code block without a hook",
+			hooks:   map[string]string{"codeblock-mermaid.html": "mermaid"},
+			content: "```go\nx\n```",
+			want:    "<pre><code class=\"language-go\">x\n</code></pre>\n",
+		},
+		{
+			name:    "attributes",
+			hooks:   map[string]string{"codeblock.html": "{{ .Attributes.title }} {{ .Attributes.linenos }}"},
+			content: "```go title=\"main.go\" linenos=true\nx\n```",
+			want:    "main.go true\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pagesPath := t.TempDir()
+			if err := os.Mkdir(filepath.Join(pagesPath, renderHooksDir), 0755); err != nil {
+				t.Fatal(err)
+			}
+			for name, content := range tt.hooks {
+				if err := os.WriteFile(filepath.Join(pagesPath, renderHooksDir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			hooks, err := CollectRenderHooks(pagesPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			setRenderHooks(hooks)
+
+			var got bytes.Buffer
+			if err := mdProcessor.Convert([]byte(tt.content), &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("Convert(%q) = %q, want %q", tt.content, got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectRenderHooks(t *testing.T) {
+	pagesPath := t.TempDir()
+	if hooks, err := CollectRenderHooks(pagesPath); hooks != nil || err != nil {
+		t.Errorf("CollectRenderHooks() without the directory = %v, %v, want nil, nil", hooks, err)
+	}
+
+	hooksPath := filepath.Join(pagesPath, renderHooksDir)
+	if err := os.Mkdir(hooksPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(hooksPath, "notes.txt"), []byte("{{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if hooks, err := CollectRenderHooks(pagesPath); hooks != nil || err != nil {
+		t.Errorf("CollectRenderHooks() without templates = %v, %v, want nil, nil", hooks, err)
+	}
+
+	if err := os.WriteFile(filepath.Join(hooksPath, "link.html"), []byte("{{ .Text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CollectRenderHooks(pagesPath); err == nil {
+		t.Error("CollectRenderHooks() with an invalid template didn't return an error")
+	}
+}
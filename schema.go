@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// schemaFileName is looked up in every directory of `pages`, the
+// schema applies to all files in the directory and the ones nested in
+// it, unless a nested directory has its own schema
+const schemaFileName = "_schema.json"
+
+// Schema is the subset of JSON Schema that's used to
+// validate frontmatter
+type Schema struct {
+	Type                 schemaTypes        `json:"type"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	Enum                 []interface{}      `json:"enum"`
+	Format               string             `json:"format"`
+	Items                *Schema            `json:"items"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+}
+
+// schemaTypes allows `type` to be either a single type or a list of them
+type schemaTypes []string
+
+func (st *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*st = schemaTypes{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("`type` should be a string or a list of strings")
+	}
+	*st = multiple
+	return nil
+}
+
+// SchemaViolation is a single key of a file that didn't
+// match its schema
+type SchemaViolation struct {
+	File    string
+	Key     string
+	Message string
+}
+
+func (sv SchemaViolation) String() string {
+	return fmt.Sprintf("%v: %v: %v", sv.File, sv.Key, sv.Message)
+}
+
+// SchemaCollection maps directories to the schema defined in them
+type SchemaCollection map[string]*Schema
+
+// CollectSchemas finds all schema files in the pages directory
+func CollectSchemas(pagesPath string) (SchemaCollection, error) {
+	schemas := SchemaCollection{}
+
+	err := filepath.WalkDir(pagesPath, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != schemaFileName {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		var schema Schema
+		if err := json.Unmarshal(data, &schema); err != nil {
+			return fmt.Errorf("%v: invalid schema, %v", path, err)
+		}
+		schemas[filepath.Dir(path)] = &schema
+		return nil
+	})
+
+	if os.IsNotExist(err) {
+		return schemas, nil
+	}
+
+	return schemas, err
+}
+
+// For returns the closest schema for the given source file
+func (sc SchemaCollection) For(sourcePath string) *Schema {
+	dir := filepath.Dir(sourcePath)
+	for {
+		if schema, ok := sc[dir]; ok {
+			return schema
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// Validate checks the meta of the file against its schema
+func (sc SchemaCollection) Validate(af *AlvuFile) []SchemaViolation {
+	schema := sc.For(af.sourcePath)
+	if schema == nil {
+		return nil
+	}
+
+	violations := []SchemaViolation{}
+	var meta interface{} = map[string]interface{}{}
+	if af.meta != nil {
+		meta = af.meta
+	}
+	schema.validate("", meta, func(key, message string) {
+		if key == "" {
+			key = "(frontmatter)"
+		}
+		violations = append(violations, SchemaViolation{
+			File:    af.sourcePath,
+			Key:     key,
+			Message: message,
+		})
+	})
+	return violations
+}
+
+func (s *Schema) validate(key string, value interface{}, report func(key, message string)) {
+	if len(s.Type) > 0 {
+		matched := false
+		for _, t := range s.Type {
+			if schemaTypeMatches(t, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			report(key, fmt.Sprintf("expected %v, got %v", strings.Join(s.Type, " or "), describeValue(value)))
+			return
+		}
+	}
+
+	if len(s.Enum) > 0 {
+		matched := false
+		for _, option := range s.Enum {
+			if valuesEqual(option, value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			options := []string{}
+			for _, option := range s.Enum {
+				options = append(options, fmt.Sprintf("%v", option))
+			}
+			report(key, fmt.Sprintf("%v is not one of [%v]", value, strings.Join(options, ", ")))
+		}
+	}
+
+	if s.Format != "" {
+		if err := validateFormat(s.Format, value); err != nil {
+			report(key, err.Error())
+		}
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		for _, required := range s.Required {
+			if _, ok := typed[required]; !ok {
+				report(joinKey(key, required), "is required")
+			}
+		}
+
+		keys := make([]string, 0, len(typed))
+		for k := range typed {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			propSchema, ok := s.Properties[k]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					report(joinKey(key, k), "is not an allowed key")
+				}
+				continue
+			}
+			propSchema.validate(joinKey(key, k), typed[k], report)
+		}
+	case []interface{}:
+		if s.Items == nil {
+			return
+		}
+		for i, item := range typed {
+			s.Items.validate(fmt.Sprintf("%v[%v]", key, i), item, report)
+		}
+	}
+}
+
+func joinKey(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
+
+func schemaTypeMatches(schemaType string, value interface{}) bool {
+	switch schemaType {
+	case "null":
+		return value == nil
+	case "string":
+		// toml has native dates, treat them as formatted strings
+		switch value.(type) {
+		case string, time.Time:
+			return true
+		}
+		return false
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		f, ok := toFloat(value)
+		return ok && f == math.Trunc(f)
+	}
+	return false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := reflect.ValueOf(value); v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func valuesEqual(a, b interface{}) bool {
+	af, aIsNum := toFloat(a)
+	bf, bIsNum := toFloat(b)
+	if aIsNum && bIsNum {
+		return af == bf
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func describeValue(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case time.Time:
+		return "date"
+	}
+	if _, ok := toFloat(value); ok {
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+var schemaDateLayouts = map[string][]string{
+	"date":      {"2006-01-02"},
+	"date-time": {time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05"},
+	"time":      {"15:04:05", "15:04"},
+}
+
+func validateFormat(format string, value interface{}) error {
+	layouts, ok := schemaDateLayouts[format]
+	if !ok {
+		// unknown formats are only annotations, same as JSON Schema
+		return nil
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return nil
+	case string:
+		for _, layout := range layouts {
+			if _, err := time.Parse(layout, v); err == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not a valid %v", v, format)
+	}
+	return fmt.Errorf("expected a %v", format)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		// frontmatter of the file, as yaml
+		meta string
+		want []string
+	}{
+		{
+			name:   "valid",
+			schema: `{"type": "object", "required": ["title"], "properties": {"title": {"type": "string"}}}`,
+			meta:   "title: Hello",
+			want:   []string{},
+		},
+		{
+			name:   "missing required keys",
+			schema: `{"required": ["title", "date"]}`,
+			meta:   "",
+			want:   []string{"pages/post.md: title: is required", "pages/post.md: date: is required"},
+		},
+		{
+			name:   "types",
+			schema: `{"properties": {"draft": {"type": "boolean"}, "weight": {"type": "integer"}, "rating": {"type": ["number", "null"]}}}`,
+			meta:   "draft: \"no\"\nweight: 1.5\nrating: ~",
+			want:   []string{"pages/post.md: draft: expected boolean, got string", "pages/post.md: weight: expected integer, got number"},
+		},
+		{
+			name:   "enum",
+			schema: `{"properties": {"status": {"enum": ["draft", "published"]}, "level": {"enum": [1, 2]}}}`,
+			meta:   "status: archived\nlevel: 2",
+			want:   []string{"pages/post.md: status: archived is not one of [draft, published]"},
+		},
+		{
+			name:   "formats",
+			schema: `{"properties": {"date": {"format": "date"}, "updated": {"format": "date-time"}, "slug": {"format": "slug"}}}`,
+			meta:   "date: \"2024-13-01\"\nupdated: 2024-01-02T10:00:00Z\nslug: anything",
+			want:   []string{"pages/post.md: date: \"2024-13-01\" is not a valid date"},
+		},
+		{
+			name:   "nested keys and items",
+			schema: `{"properties": {"author": {"type": "object", "required": ["name"]}, "tags": {"type": "array", "items": {"type": "string"}}}}`,
+			meta:   "author:\n  email: a@b.c\ntags: [go, 1]",
+			want:   []string{"pages/post.md: author.name: is required", "pages/post.md: tags[1]: expected string, got number"},
+		},
+		{
+			name:   "additional properties",
+			schema: `{"additionalProperties": false, "properties": {"title": {}}}`,
+			meta:   "title: a\ntitel: b",
+			want:   []string{"pages/post.md: titel: is not an allowed key"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var schema Schema
+			if err := json.Unmarshal([]byte(tt.schema), &schema); err != nil {
+				t.Fatal(err)
+			}
+			af := &AlvuFile{sourcePath: "pages/post.md"}
+			if _, err := unmarshalData("yaml", []byte(tt.meta), &af.meta); err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, violation := range (SchemaCollection{"pages": &schema}).Validate(af) {
+				got = append(got, violation.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectSchemas(t *testing.T) {
+	pagesPath := filepath.Join(t.TempDir(), "pages")
+	for name, content := range map[string]string{
+		"_schema.json":      `{"required": ["title"]}`,
+		"blog/_schema.json": `{"required": ["date"]}`,
+	} {
+		path := filepath.Join(pagesPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	schemas, err := CollectSchemas(pagesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"index.md", []string{"title"}},
+		{"blog/post.md", []string{"date"}},
+		{"blog/2024/old.md", []string{"date"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			schema := schemas.For(filepath.Join(pagesPath, tt.file))
+			if schema == nil || !reflect.DeepEqual(schema.Required, tt.want) {
+				t.Errorf("For(%v) = %v, want a schema requiring %v", tt.file, schema, tt.want)
+			}
+		})
+	}
+
+	if err := os.WriteFile(filepath.Join(pagesPath, "_schema.json"), []byte(`{"type": 1}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CollectSchemas(pagesPath); err == nil {
+		t.Error("CollectSchemas() with an invalid schema didn't return an error")
+	}
+}
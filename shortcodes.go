@@ -0,0 +1,602 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+var shortcodeNamePattern = regexp.MustCompile(`^[\w-]+$`)
+
+var shortcodePlaceholderPattern = regexp.MustCompile(`(?:<p>)?alvu-shortcode-(\d+)-end(?:</p>\n?)?`)
+
+// Shortcode is a template or a lua script from the
+// shortcodes directory, named after the file
+type Shortcode struct {
+	path     string
+	template *template.Template
+	state    *lua.LState
+	// shortcodes that come with alvu
+	builtin func(data *ShortcodeData) (string, error)
+	// the output goes back into the page as it is to go
+	// through the template pass and markdown with it
+	inline bool
+}
+
+type ShortcodeCollection map[string]*Shortcode
+
+// ShortcodeData is what shortcode templates are executed with
+type ShortcodeData struct {
+	Name string
+	// positional arguments
+	Args []string
+	// named arguments, `key=value`
+	Params map[string]string
+	// content between the opening and closing tags with
+	// the shortcodes in it already expanded
+	Inner template.HTML
+	// the shortcode this one is nested in
+	Parent *ShortcodeData
+	Page   *PageData
+	Site   SiteData
+	Meta   SiteMeta
+
+	expansion *shortcodeExpansion
+	// called with `{{% %}}`
+	markdown bool
+}
+
+// Get returns the positional argument for an index
+// or the named one for a string
+func (sd *ShortcodeData) Get(key any) string {
+	switch k := key.(type) {
+	case int:
+		if k >= 0 && k < len(sd.Args) {
+			return sd.Args[k]
+		}
+	case string:
+		return sd.Params[k]
+	}
+	return ""
+}
+
+// templateFuncs are available to shortcode and render hook templates
+var templateFuncs = template.FuncMap{
+	"markdownify": func(content any) (template.HTML, error) {
+		var buf bytes.Buffer
+		if err := mdProcessor.Convert([]byte(fmt.Sprint(content)), &buf); err != nil {
+			return "", err
+		}
+		return template.HTML(buf.String()), nil
+	},
+	"hasPrefix": strings.HasPrefix,
+	"hasSuffix": strings.HasSuffix,
+	"contains":  strings.Contains,
+}
+
+// CollectShortcodes loads the `.html` templates and `.lua` scripts
+// in the shortcodes directory, lua shortcodes define a `Shortcode`
+// function that gets the call as json and returns the output
+func CollectShortcodes(shortcodesPath string) (ShortcodeCollection, error) {
+	collection := ShortcodeCollection{}
+
+	entries, err := os.ReadDir(shortcodesPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		ext := filepath.Ext(entry.Name())
+		name := strings.TrimSuffix(entry.Name(), ext)
+		shortcodePath := filepath.Join(shortcodesPath, entry.Name())
+		if ext != ".html" && ext != ".lua" {
+			continue
+		}
+		if existing, ok := collection[name]; ok {
+			return nil, fmt.Errorf("%v: shortcode `%v` is already defined by %v", shortcodePath, name, existing.path)
+		}
+
+		shortcode := &Shortcode{path: shortcodePath}
+
+		switch ext {
+		case ".html":
+			data, err := os.ReadFile(shortcodePath)
+			if err != nil {
+				return nil, err
+			}
+			shortcode.template, err = template.New(entry.Name()).Funcs(templateFuncs).Parse(string(data))
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", shortcodePath, err)
+			}
+		case ".lua":
+			state := NewHook()
+			if err := state.DoFile(shortcodePath); err != nil {
+				state.Close()
+				return nil, err
+			}
+			if state.GetGlobal("Shortcode").Type() != lua.LTFunction {
+				state.Close()
+				return nil, fmt.Errorf("%v: missing the `Shortcode` function", shortcodePath)
+			}
+			shortcode.state = state
+		}
+
+		collection[name] = shortcode
+	}
+
+	// built in shortcodes can be replaced by the site's own
+	if _, ok := collection["include"]; !ok {
+		collection["include"] = &Shortcode{builtin: includeShortcode}
+	}
+	if _, ok := collection["embed"]; !ok {
+		collection["embed"] = &Shortcode{builtin: embedShortcode, inline: true}
+	}
+
+	return collection, nil
+}
+
+func (sc ShortcodeCollection) Shutdown() {
+	for _, shortcode := range sc {
+		if shortcode.state != nil {
+			shortcode.state.Close()
+		}
+	}
+}
+
+func (s *Shortcode) execute(data *ShortcodeData) (string, error) {
+	if s.builtin != nil {
+		return s.builtin(data)
+	}
+
+	if s.template != nil {
+		var buf bytes.Buffer
+		if err := s.template.Execute(&buf, data); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+
+	input := struct {
+		Name   string            `json:"name"`
+		Args   []string          `json:"args"`
+		Params map[string]string `json:"params"`
+		Inner  string            `json:"inner"`
+		Page   struct {
+			Title string                 `json:"title"`
+			URL   string                 `json:"url"`
+			Meta  map[string]interface{} `json:"meta"`
+		} `json:"page"`
+	}{
+		Name:   data.Name,
+		Args:   data.Args,
+		Params: data.Params,
+		Inner:  string(data.Inner),
+	}
+	if data.Page != nil {
+		input.Page.Title = data.Page.Title
+		input.Page.URL = data.Page.URL
+		input.Page.Meta = data.Page.Meta
+	}
+
+	jsonInput, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.state.CallByParam(lua.P{
+		Fn:      s.state.GetGlobal("Shortcode"),
+		NRet:    1,
+		Protect: true,
+	}, lua.LString(jsonInput)); err != nil {
+		return "", err
+	}
+	ret := s.state.Get(-1)
+	s.state.Pop(1)
+
+	if ret == lua.LNil {
+		return "", nil
+	}
+	return ret.String(), nil
+}
+
+// shortcodeCall is a shortcode used in a page, calls with a
+// closing tag have the range of their inner content and the
+// calls nested in it
+type shortcodeCall struct {
+	name        string
+	args        []string
+	params      map[string]string
+	markdown    bool
+	closing     bool
+	selfClosing bool
+	// offsets of the opening tag
+	start  int
+	tagEnd int
+	// offsets of the inner content and the end of the closing tag,
+	// -1 when there's no closing tag
+	innerEnd int
+	stop     int
+	children []*shortcodeCall
+}
+
+// shortcodeExpansion expands the shortcodes of a page, their
+// output is swapped with placeholders so the template pass and
+// the markdown processor don't touch it
+type shortcodeExpansion struct {
+	shortcodes ShortcodeCollection
+	// the page being built and the file the content is from,
+	// which is a different one for embedded pages
+	page     *AlvuFile
+	file     *AlvuFile
+	data     PageRenderData
+	verbatim *verbatimStore
+	html     []string
+	// files embedded into each other to get to this one
+	embeds []string
+}
+
+// Expand replaces the shortcodes in the content. Output of
+// `{{% %}}` shortcodes is markdown and is stashed in the verbatim store,
+// output of `{{< >}}` shortcodes is HTML and has to be put back with
+// RestoreHTML after the markdown is converted
+func (sc ShortcodeCollection) Expand(af *AlvuFile, content []byte, data PageRenderData, verbatim *verbatimStore) (*shortcodeExpansion, []byte, error) {
+	expansion := &shortcodeExpansion{
+		shortcodes: sc,
+		page:       af,
+		file:       af,
+		data:       data,
+		verbatim:   verbatim,
+		embeds:     []string{af.sourcePath},
+	}
+	expanded, err := expansion.expandContent(content)
+	if err != nil {
+		return nil, nil, err
+	}
+	return expansion, expanded, nil
+}
+
+// expandContent replaces the shortcodes in content from the file
+// of the expansion
+func (se *shortcodeExpansion) expandContent(content []byte) ([]byte, error) {
+	skip := verbatimRanges(content)
+	if se.file.isMarkdown() {
+		skip = append(skip, inlineCodeRanges(content, skip)...)
+	}
+
+	calls, err := se.parse(content, skip)
+	if err != nil {
+		return nil, err
+	}
+	if len(calls) > 0 {
+		expanded, err := se.expand(content, 0, len(content), calls, nil)
+		if err != nil {
+			return nil, err
+		}
+		content = []byte(expanded)
+	}
+
+	if se.file.isMarkdown() {
+		content = se.protectCodeSpans(content)
+	}
+	return content, nil
+}
+
+// protectCodeSpans stashes the code spans that have shortcodes in
+// them, so the shortcodes can be written about without the template
+// pass failing on them
+func (se *shortcodeExpansion) protectCodeSpans(content []byte) []byte {
+	var buf bytes.Buffer
+	last := 0
+	for _, span := range inlineCodeRanges(content, verbatimRanges(content)) {
+		code := content[span[0]:span[1]]
+		if !bytes.Contains(code, []byte("{{<")) && !bytes.Contains(code, []byte("{{%")) {
+			continue
+		}
+		buf.Write(content[last:span[0]])
+		buf.Write(se.verbatim.stash(code))
+		last = span[1]
+	}
+	if last == 0 {
+		return content
+	}
+	buf.Write(content[last:])
+	return buf.Bytes()
+}
+
+// RestoreHTML puts the output of the `{{< >}}` shortcodes back, a
+// placeholder that ended up as its own paragraph is replaced along
+// with the paragraph
+func (se *shortcodeExpansion) RestoreHTML(content []byte) []byte {
+	if len(se.html) == 0 {
+		return content
+	}
+	return shortcodePlaceholderPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		submatch := shortcodePlaceholderPattern.FindSubmatch(match)
+		index, _ := strconv.Atoi(string(submatch[1]))
+		if index >= len(se.html) {
+			return match
+		}
+		output := []byte(se.html[index])
+		// only drop the paragraph when both of its tags were matched
+		if bytes.HasPrefix(match, []byte("<p>")) != bytes.Contains(match, []byte("</p>")) {
+			if bytes.HasPrefix(match, []byte("<p>")) {
+				return append([]byte("<p>"), output...)
+			}
+			return append(output, match[bytes.Index(match, []byte("</p>")):]...)
+		}
+		return output
+	})
+}
+
+func (se *shortcodeExpansion) errorAt(content []byte, offset int, format string, args ...any) error {
+	line := se.file.bodyLine() + bytes.Count(content[:offset], []byte("\n"))
+	return fmt.Errorf("%v:%v: %v", se.file.sourcePath, line, fmt.Sprintf(format, args...))
+}
+
+// parse finds the shortcode tags in the content and pairs them
+// with their closing tags, tags without one have no inner content
+func (se *shortcodeExpansion) parse(content []byte, skip [][2]int) ([]*shortcodeCall, error) {
+	root := &shortcodeCall{}
+	stack := []*shortcodeCall{root}
+
+	// a call that never got closed is a sibling of
+	// the calls that were nested in it
+	unwind := func(to int) {
+		for len(stack)-1 > to {
+			open := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			parent := stack[len(stack)-1]
+			children := open.children
+			open.children = nil
+			parent.children = append(parent.children, open)
+			parent.children = append(parent.children, children...)
+		}
+	}
+
+	offset := 0
+	for {
+		index := bytes.Index(content[offset:], []byte("{{"))
+		if index == -1 {
+			break
+		}
+		index += offset
+
+		skipped := false
+		for _, r := range skip {
+			if index >= r[0] && index < r[1] {
+				offset = r[1]
+				skipped = true
+				break
+			}
+		}
+		if skipped {
+			continue
+		}
+
+		if index+2 >= len(content) || (content[index+2] != '<' && content[index+2] != '%') {
+			offset = index + 2
+			continue
+		}
+
+		call, err := se.parseTag(content, index)
+		if err != nil {
+			return nil, err
+		}
+		offset = call.tagEnd
+
+		if _, ok := se.shortcodes[call.name]; !ok {
+			return nil, se.errorAt(content, call.start, "unknown shortcode `%v`", call.name)
+		}
+
+		if !call.closing {
+			if call.selfClosing {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, call)
+			} else {
+				stack = append(stack, call)
+			}
+			continue
+		}
+
+		opening := -1
+		for i := len(stack) - 1; i > 0; i-- {
+			if stack[i].name == call.name {
+				opening = i
+				break
+			}
+		}
+		if opening == -1 {
+			return nil, se.errorAt(content, call.start, "closing tag for `%v` without an opening tag", call.name)
+		}
+
+		unwind(opening)
+		open := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		open.innerEnd = call.start
+		open.stop = call.tagEnd
+		parent := stack[len(stack)-1]
+		parent.children = append(parent.children, open)
+	}
+
+	unwind(0)
+	return root.children, nil
+}
+
+// parseTag reads the tag that starts at the offset, arguments are
+// separated by whitespace and can be quoted or `key=value` pairs
+func (se *shortcodeExpansion) parseTag(content []byte, start int) (*shortcodeCall, error) {
+	call := &shortcodeCall{
+		start:    start,
+		markdown: content[start+2] == '%',
+		innerEnd: -1,
+		stop:     -1,
+		args:     []string{},
+		params:   map[string]string{},
+	}
+	closeDelim := []byte(">}}")
+	if call.markdown {
+		closeDelim = []byte("%}}")
+	}
+
+	readValue := func(pos int) (string, int, error) {
+		if pos < len(content) && (content[pos] == '"' || content[pos] == '`') {
+			quote := content[pos]
+			end := pos + 1
+			for end < len(content) && content[end] != quote {
+				if quote == '"' && content[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(content) {
+				return "", end, se.errorAt(content, start, "unterminated string in shortcode")
+			}
+			if quote == '`' {
+				return string(content[pos+1 : end]), end + 1, nil
+			}
+			value, err := strconv.Unquote(string(content[pos : end+1]))
+			if err != nil {
+				return "", end, se.errorAt(content, start, "invalid string %v in shortcode", string(content[pos:end+1]))
+			}
+			return value, end + 1, nil
+		}
+
+		end := pos
+		for end < len(content) &&
+			!isSpaceByte(content[end]) &&
+			content[end] != '=' &&
+			!bytes.HasPrefix(content[end:], closeDelim) {
+			end++
+		}
+		return string(content[pos:end]), end, nil
+	}
+
+	pos := start + 3
+	first := true
+	for {
+		for pos < len(content) && isSpaceByte(content[pos]) {
+			pos++
+		}
+		if pos >= len(content) {
+			return nil, se.errorAt(content, start, "unclosed shortcode tag")
+		}
+		if bytes.HasPrefix(content[pos:], closeDelim) {
+			call.tagEnd = pos + len(closeDelim)
+			break
+		}
+		if content[pos] == '/' && bytes.HasPrefix(content[pos+1:], closeDelim) && !first {
+			call.selfClosing = true
+			call.tagEnd = pos + 1 + len(closeDelim)
+			break
+		}
+
+		value, next, err := readValue(pos)
+		if err != nil {
+			return nil, err
+		}
+		if next == pos {
+			return nil, se.errorAt(content, start, "unexpected `%c` in shortcode", content[pos])
+		}
+		pos = next
+
+		if first {
+			first = false
+			if strings.HasPrefix(value, "/") {
+				call.closing = true
+				value = value[1:]
+			}
+			if !shortcodeNamePattern.MatchString(value) {
+				return nil, se.errorAt(content, start, "invalid shortcode name `%v`", value)
+			}
+			call.name = value
+			continue
+		}
+
+		if call.closing {
+			return nil, se.errorAt(content, start, "closing tag for `%v` can't have arguments", call.name)
+		}
+
+		if pos < len(content) && content[pos] == '=' {
+			paramValue, next, err := readValue(pos + 1)
+			if err != nil {
+				return nil, err
+			}
+			call.params[value] = paramValue
+			pos = next
+			continue
+		}
+		call.args = append(call.args, value)
+	}
+
+	return call, nil
+}
+
+// expand renders the calls in the range of the content, the
+// output of nested calls is part of their parent's inner content
+func (se *shortcodeExpansion) expand(content []byte, start, end int, calls []*shortcodeCall, parent *ShortcodeData) (string, error) {
+	var buf strings.Builder
+	last := start
+
+	for _, call := range calls {
+		buf.Write(content[last:call.start])
+
+		data := &ShortcodeData{
+			Name:   call.name,
+			Args:   call.args,
+			Params: call.params,
+			Parent: parent,
+			Page:   se.data.Page,
+			Site:   se.data.Site,
+			Meta:   se.data.Meta,
+
+			expansion: se,
+			markdown:  call.markdown,
+		}
+		last = call.tagEnd
+
+		if call.stop != -1 {
+			inner, err := se.expand(content, call.tagEnd, call.innerEnd, call.children, data)
+			if err != nil {
+				return "", err
+			}
+			data.Inner = template.HTML(inner)
+			last = call.stop
+		}
+
+		output, err := se.shortcodes[call.name].execute(data)
+		if err != nil {
+			return "", se.errorAt(content, call.start, "shortcode `%v`, %v", call.name, err)
+		}
+
+		switch {
+		case parent != nil, se.shortcodes[call.name].inline:
+			buf.WriteString(output)
+		case call.markdown:
+			buf.Write(se.verbatim.stash([]byte(output)))
+		default:
+			se.html = append(se.html, output)
+			fmt.Fprintf(&buf, "alvu-shortcode-%d-end", len(se.html)-1)
+		}
+	}
+
+	buf.Write(content[last:end])
+	return buf.String(), nil
+}
+
+func isSpaceByte(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testExpansion has shortcodes that write out how they were called,
+// `[name args params|inner]`, straight into the content
+func testExpansion(sourcePath string) *shortcodeExpansion {
+	describe := func(data *ShortcodeData) (string, error) {
+		params := []string{}
+		for key, value := range data.Params {
+			params = append(params, key+"="+value)
+		}
+		sort.Strings(params)
+		call := strings.Join(append(append([]string{data.Name}, data.Args...), params...), " ")
+		if data.Inner != "" {
+			call += "|" + string(data.Inner)
+		}
+		return "[" + call + "]", nil
+	}
+
+	shortcodes := ShortcodeCollection{}
+	for _, name := range []string{"youtube", "note", "box"} {
+		shortcodes[name] = &Shortcode{builtin: describe, inline: true}
+	}
+	return &shortcodeExpansion{
+		shortcodes: shortcodes,
+		file:       &AlvuFile{sourcePath: sourcePath},
+		verbatim:   &verbatimStore{},
+	}
+}
+
+func TestShortcodeExpand(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "self closing",
+			content: "a {{< youtube id >}} b",
+			want:    "a [youtube id] b",
+		},
+		{
+			name:    "markdown",
+			content: "{{% note %}}",
+			want:    "[note]",
+		},
+		{
+			name:    "named and quoted arguments",
+			content: "{{< youtube id title=\"Never gonna\" start=`1 2` >}}",
+			want:    "[youtube id start=1 2 title=Never gonna]",
+		},
+		{
+			name:    "escapes in quoted arguments",
+			content: `{{< youtube "a \"b\"" >}}`,
+			want:    `[youtube a "b"]`,
+		},
+		{
+			name:    "delimiters in quoted arguments",
+			content: `{{< youtube ">}}" >}}`,
+			want:    `[youtube >}}]`,
+		},
+		{
+			name:    "no spaces",
+			content: "{{<youtube id>}}",
+			want:    "[youtube id]",
+		},
+		{
+			name:    "inner content",
+			content: "{{% note type=warning %}}some *text*{{% /note %}}",
+			want:    "[note type=warning|some *text*]",
+		},
+		{
+			name:    "nested",
+			content: "{{% note %}}a {{< youtube x >}} b{{% /note %}}",
+			want:    "[note|a [youtube x] b]",
+		},
+		{
+			name:    "nested with the same name",
+			content: "{{< box >}}{{< box >}}x{{< /box >}}{{< /box >}}",
+			want:    "[box|[box|x]]",
+		},
+		{
+			name:    "unclosed is self closing",
+			content: "{{< box >}} and {{< youtube >}}",
+			want:    "[box] and [youtube]",
+		},
+		{
+			name:    "unclosed inside a closed one",
+			content: "{{< note >}}{{< box >}}x{{< /note >}}",
+			want:    "[note|[box]x]",
+		},
+		{
+			name:    "templates are left alone",
+			content: "{{ .Page.Title }} {{- .X -}}",
+			want:    "{{ .Page.Title }} {{- .X -}}",
+		},
+		{
+			name:    "fenced code is left alone",
+			content: "```\n{{< nope >}}\n```\n{{< youtube >}}",
+			want:    "```\n{{< nope >}}\n```\n[youtube]",
+		},
+		{
+			name:    "raw blocks are left alone",
+			content: "{{< raw >}}{{< nope >}}{{< /raw >}}",
+			want:    "{{< raw >}}{{< nope >}}{{< /raw >}}",
+		},
+		{
+			name:    "inline code is left alone",
+			content: "use `{{< nope >}}` or ``{{% nope `x` %}}`` and {{< youtube >}}",
+			want:    "use `{{< nope >}}` or ``{{% nope `x` %}}`` and [youtube]",
+		},
+		{
+			name:    "a single backtick isn't code",
+			content: "a ` {{< youtube >}}",
+			want:    "a ` [youtube]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			se := testExpansion("pages/index.md")
+			got, err := se.expandContent([]byte(tt.content))
+			if err != nil {
+				t.Fatalf("expandContent(%q) error = %v", tt.content, err)
+			}
+			if restored := string(se.verbatim.Restore(got)); restored != tt.want {
+				t.Errorf("expandContent(%q) = %q, want %q", tt.content, restored, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortcodeExpandHTML(t *testing.T) {
+	// inline code is only a thing in markdown
+	se := testExpansion("pages/index.html")
+	got, err := se.expandContent([]byte("<code>`{{< youtube >}}`</code>"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<code>`[youtube]`</code>"; string(got) != want {
+		t.Errorf("expandContent() = %q, want %q", got, want)
+	}
+}
+
+func TestShortcodeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "unknown shortcode",
+			content: "a\n\n{{< nope >}}",
+			want:    "pages/index.md:3: unknown shortcode `nope`",
+		},
+		{
+			name:    "closing tag without an opening tag",
+			content: "{{< /box >}}",
+			want:    "pages/index.md:1: closing tag for `box` without an opening tag",
+		},
+		{
+			name:    "closing tag with arguments",
+			content: "{{< box >}}x{{< /box a >}}",
+			want:    "pages/index.md:1: closing tag for `box` can't have arguments",
+		},
+		{
+			name:    "unclosed tag",
+			content: "{{< youtube id",
+			want:    "pages/index.md:1: unclosed shortcode tag",
+		},
+		{
+			name:    "mismatched delimiters",
+			content: "text\n{{< youtube id %}}",
+			want:    "pages/index.md:2: unclosed shortcode tag",
+		},
+		{
+			name:    "unterminated string",
+			content: "{{< youtube \"id >}}",
+			want:    "pages/index.md:1: unterminated string in shortcode",
+		},
+		{
+			name:    "invalid name",
+			content: "{{< you!tube >}}",
+			want:    "pages/index.md:1: invalid shortcode name `you!tube`",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := testExpansion("pages/index.md").expandContent([]byte(tt.content))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("expandContent(%q) error = %v, want %v", tt.content, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestShortcodeErrorLine(t *testing.T) {
+	// lines are counted from the start of the file, frontmatter included
+	af := &AlvuFile{sourcePath: "pages/index.md"}
+	af.content = []byte("---\ntitle: a\n---\nbody\n{{< nope >}}")
+	af.writeableContent = af.content[len("---\ntitle: a\n---\n"):]
+
+	se := testExpansion("pages/index.md")
+	se.file = af
+	_, err := se.expandContent(af.writeableContent)
+	if want := "pages/index.md:5: unknown shortcode `nope`"; fmt.Sprint(err) != want {
+		t.Errorf("expandContent() error = %v, want %v", err, want)
+	}
+}
+
+func TestInlineCodeRanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"single", "a `b` c", []string{"`b`"}},
+		{"double", "a ``b ` c`` d", []string{"``b ` c``"}},
+		{"unmatched", "a ` b", []string{}},
+		{"longer run doesn't close", "`a`` b", []string{}},
+		{"escaped", "\\`a` `b`", []string{"` `"}},
+		{"across lines", "`a\nb`", []string{"`a\nb`"}},
+		{"not across paragraphs", "`a\n\nb`", []string{}},
+		{"skipped fence", "```\n`a\n```\n`b`", []string{"`b`"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, r := range inlineCodeRanges([]byte(tt.content), fencedCodeRanges([]byte(tt.content))) {
+				got = append(got, tt.content[r[0]:r[1]])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("inlineCodeRanges(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
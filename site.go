@@ -0,0 +1,425 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// orderPrefixPattern matches the `01-` used to order files and directories
+var orderPrefixPattern = regexp.MustCompile(`^(\d+)-(.+)$`)
+
+// datePrefixPattern avoids treating `2024-01-01-post.md` as an ordered file
+var datePrefixPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+
+var htmlHeadingPattern = regexp.MustCompile(`(?is)<h1[^>]*>(.*?)</h1>`)
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// PageData is the information about the
+// page that's being rendered, sections also
+// carry the pages and sections nested in them
+type PageData struct {
+	Title     string
+	URL       string
+	Weight    int
+	Date      time.Time
+	Meta      map[string]interface{}
+	IsSection bool
+	Parent    *PageData
+	Pages     []*PageData
+	Sections  []*PageData
+	// siblings in the order of the section
+	Prev *PageData
+	Next *PageData
+	// sections above the page starting from the root
+	Ancestors []*PageData
+	// pages linking to this one with wiki links
+	Backlinks []*PageData
+
+	// the file of the page, the summary and table of
+	// contents come from its rendered content
+	file *AlvuFile
+	dir  string
+	// weight was set by the frontmatter or a prefix,
+	// a `00-` prefix still orders the page
+	weighted bool
+}
+
+func newPageData(af *AlvuFile) *PageData {
+	page := &PageData{
+		URL:  af.URL(),
+		Meta: af.meta,
+		file: af,
+		dir:  filepath.Dir(af.sourcePath),
+	}
+
+	if title, ok := af.meta["title"].(string); ok {
+		page.Title = title
+	} else if heading := af.firstHeading(); heading != "" {
+		page.Title = heading
+	} else {
+		page.Title = humanize(af.orderedName())
+	}
+
+	if weight, ok := toFloat(af.meta["weight"]); ok {
+		page.Weight, page.weighted = int(weight), true
+	} else if weight, _, ok := splitOrderPrefix(af.orderedName()); ok {
+		page.Weight, page.weighted = weight, true
+	}
+
+	if date, ok := parseDate(af.meta["date"]); ok {
+		page.Date = date
+	}
+
+	return page
+}
+
+// BuildPageTree creates the page data for every file and groups
+// them into sections, any directory with an _index.md is a section
+// and the pages directory is always the root section
+func (al *Alvu) BuildPageTree() {
+	sections := map[string]*PageData{}
+
+	for _, af := range al.files {
+		af.page = newPageData(af)
+		if filepath.Base(af.sourcePath) == sectionIndexName {
+			af.page.IsSection = true
+			sections[af.page.dir] = af.page
+		}
+	}
+
+	if _, ok := sections[al.pagesPath]; !ok {
+		sections[al.pagesPath] = &PageData{
+			Title:     "Home",
+			URL:       joinURL(baseurl, ""),
+			IsSection: true,
+			dir:       al.pagesPath,
+		}
+	}
+	al.root = sections[al.pagesPath]
+
+	closestSection := func(dir string) *PageData {
+		for dir != al.pagesPath && strings.HasPrefix(dir, al.pagesPath) {
+			dir = filepath.Dir(dir)
+			if section, ok := sections[dir]; ok {
+				return section
+			}
+		}
+		return nil
+	}
+
+	for dir, section := range sections {
+		if dir == al.pagesPath {
+			continue
+		}
+		parent := closestSection(dir)
+		section.Parent = parent
+		parent.Sections = append(parent.Sections, section)
+	}
+
+	for _, af := range al.files {
+		if af.page.IsSection {
+			continue
+		}
+		parent, ok := sections[af.page.dir]
+		if !ok {
+			parent = closestSection(af.page.dir)
+		}
+		af.page.Parent = parent
+		if af.isListed() {
+			parent.Pages = append(parent.Pages, af.page)
+		}
+	}
+
+	for _, section := range sections {
+		sortBy, _ := section.Meta["sort_by"].(string)
+		sortPages(section.Pages, sortBy)
+		sortPages(section.Sections, sortBy)
+		// in the same order as the navigation
+		linkSiblings(section.entries())
+	}
+
+	for _, af := range al.files {
+		af.page.Ancestors = []*PageData{}
+		for parent := af.page.Parent; parent != nil; parent = parent.Parent {
+			af.page.Ancestors = append([]*PageData{parent}, af.page.Ancestors...)
+		}
+	}
+	al.BuildWikiLinks()
+}
+
+// entries are the pages and sections of the section
+// in a single list, in the order of the section
+func (p *PageData) entries() []*PageData {
+	entries := append(append([]*PageData{}, p.Pages...), p.Sections...)
+	sortBy, _ := p.Meta["sort_by"].(string)
+	sortPages(entries, sortBy)
+	return entries
+}
+
+func linkSiblings(pages []*PageData) {
+	for i, page := range pages {
+		page.Prev, page.Next = nil, nil
+		if i > 0 {
+			page.Prev = pages[i-1]
+		}
+		if i < len(pages)-1 {
+			page.Next = pages[i+1]
+		}
+	}
+}
+
+// isListed is false for files that shouldn't show up in
+// section listings, like the 404 page or non HTML output
+func (af *AlvuFile) isListed() bool {
+	ext := filepath.Ext(af.name)
+	if ext != ".md" && ext != ".html" {
+		return false
+	}
+	return strings.TrimSuffix(filepath.Base(af.name), ext) != "404"
+}
+
+// sortPages orders pages by weight, then newest first and
+// then by title. `sortBy` can force `date` or `title` instead
+func sortPages(pages []*PageData, sortBy string) {
+	byWeight := func(a, b *PageData) (bool, bool) {
+		// pages without a weight go last
+		if a.weighted != b.weighted {
+			return a.weighted, true
+		}
+		if a.Weight == b.Weight {
+			return false, false
+		}
+		return a.Weight < b.Weight, true
+	}
+	byDate := func(a, b *PageData) (bool, bool) {
+		if a.Date.Equal(b.Date) {
+			return false, false
+		}
+		return a.Date.After(b.Date), true
+	}
+	byTitle := func(a, b *PageData) (bool, bool) {
+		if a.Title == b.Title {
+			return a.URL < b.URL, true
+		}
+		return strings.ToLower(a.Title) < strings.ToLower(b.Title), true
+	}
+
+	order := []func(a, b *PageData) (bool, bool){byWeight, byDate, byTitle}
+	switch sortBy {
+	case "date":
+		order = []func(a, b *PageData) (bool, bool){byDate, byTitle}
+	case "title":
+		order = []func(a, b *PageData) (bool, bool){byTitle}
+	}
+
+	sort.SliceStable(pages, func(i, j int) bool {
+		for _, compare := range order {
+			if less, decided := compare(pages[i], pages[j]); decided {
+				return less
+			}
+		}
+		return false
+	})
+}
+
+// orderedName is the name that decides the order and default title of
+// the page, index files take it from the directory they are in
+func (af *AlvuFile) orderedName() string {
+	ext := filepath.Ext(af.name)
+	justFileName := strings.TrimSuffix(filepath.Base(af.name), ext)
+	if justFileName != "index" && justFileName != "_index" {
+		return justFileName
+	}
+
+	dir := filepath.Dir(af.name)
+	if dir == "." {
+		return "home"
+	}
+	return filepath.Base(dir)
+}
+
+// firstHeading returns the text of the first level 1 heading in the
+// content of the file, headings with template actions or shortcodes
+// are skipped since they're only known once the page is rendered
+func (af *AlvuFile) firstHeading() string {
+	usable := func(heading string) bool {
+		return heading != "" && (!af.isTemplated() || !strings.Contains(heading, "{{"))
+	}
+
+	if af.isMarkdown() {
+		md, err := af.markdownProcessor()
+		if err != nil || md == nil {
+			return ""
+		}
+		doc := parseMarkdown(md, af.writeableContent, nil)
+		heading := ""
+		ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if h, ok := n.(*ast.Heading); ok && entering && h.Level == 1 {
+				if text := strings.TrimSpace(nodeText(h, af.writeableContent)); usable(text) {
+					heading = text
+					return ast.WalkStop, nil
+				}
+				return ast.WalkSkipChildren, nil
+			}
+			return ast.WalkContinue, nil
+		})
+		return heading
+	}
+
+	if filepath.Ext(af.name) == ".html" {
+		for _, match := range htmlHeadingPattern.FindAllSubmatch(af.writeableContent, -1) {
+			if text := strings.TrimSpace(htmlTagPattern.ReplaceAllString(string(match[1]), "")); usable(text) {
+				return text
+			}
+		}
+	}
+
+	return ""
+}
+
+// nodeText collects the plain text of a markdown node
+func nodeText(n ast.Node, source []byte) string {
+	var buf strings.Builder
+	ast.Walk(n, func(child ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch typed := child.(type) {
+		case *ast.Text:
+			buf.Write(typed.Segment.Value(source))
+			if typed.SoftLineBreak() || typed.HardLineBreak() {
+				buf.WriteByte(' ')
+			}
+		case *ast.String:
+			buf.Write(typed.Value)
+		}
+		return ast.WalkContinue, nil
+	})
+	return buf.String()
+}
+
+// splitOrderPrefix separates the numeric prefix from a
+// file or directory name, `01-basics` => 1, `basics`
+func splitOrderPrefix(name string) (int, string, bool) {
+	if datePrefixPattern.MatchString(name) {
+		return 0, name, false
+	}
+	match := orderPrefixPattern.FindStringSubmatch(name)
+	if match == nil {
+		return 0, name, false
+	}
+	weight, err := strconv.Atoi(match[1])
+	if err != nil {
+		return 0, name, false
+	}
+	return weight, match[2], true
+}
+
+// CheckOutputPaths makes sure no two files are written to the
+// same path, `01-foo.md` and `foo.md` are both built to `foo/`
+func (al *Alvu) CheckOutputPaths() error {
+	written := map[string]*AlvuFile{}
+	for _, af := range al.files {
+		target := af.targetFilePath()
+		if other, ok := written[target]; ok {
+			if filepath.Base(other.sourcePath) == sectionIndexName || filepath.Base(af.sourcePath) == sectionIndexName {
+				return fmt.Errorf("%v and %v are both built to %v, a directory can either be a section with an %v or have an index page", other.sourcePath, af.sourcePath, target, sectionIndexName)
+			}
+			return fmt.Errorf("%v and %v are both built to %v", other.sourcePath, af.sourcePath, target)
+		}
+		written[target] = af
+	}
+	return nil
+}
+
+// stripOrderPrefixes removes the numeric prefixes
+// from every segment of the given path
+func stripOrderPrefixes(path string) string {
+	segments := strings.Split(filepath.ToSlash(path), "/")
+	for i, segment := range segments {
+		_, segments[i], _ = splitOrderPrefix(segment)
+	}
+	return filepath.FromSlash(strings.Join(segments, "/"))
+}
+
+// humanize turns a file name into a title,
+// `01-getting_started` => `Getting started`
+func humanize(name string) string {
+	_, name, _ = splitOrderPrefix(name)
+	name = strings.TrimSpace(strings.NewReplacer("-", " ", "_", " ").Replace(name))
+	if name == "" {
+		return name
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+// URL is the pretty url the file is served at
+// once it's built, see FlushFile
+func (af *AlvuFile) URL() string {
+	name := stripOrderPrefixes(af.name)
+	dir := filepath.ToSlash(filepath.Dir(name))
+	if dir == "." {
+		dir = ""
+	}
+	ext := filepath.Ext(name)
+	justFileName := strings.TrimSuffix(filepath.Base(name), ext)
+
+	switch justFileName {
+	case "index", "_index":
+		if ext == ".md" || ext == ".html" {
+			return joinURL(baseurl, dir, "")
+		}
+		return joinURL(baseurl, dir, filepath.Base(name))
+	case "404":
+		return joinURL(baseurl, dir, "404.html")
+	}
+	return joinURL(baseurl, dir, justFileName, "")
+}
+
+// joinURL joins the parts with a single `/` between them,
+// an empty last part leaves a trailing slash
+func joinURL(base string, parts ...string) string {
+	joined := strings.TrimSuffix(base, "/")
+	for _, part := range parts {
+		part = strings.Trim(part, "/")
+		if part == "" {
+			continue
+		}
+		joined += "/" + part
+	}
+	if len(parts) == 0 || parts[len(parts)-1] == "" {
+		joined += "/"
+	}
+	return joined
+}
+
+func parseDate(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		for _, layout := range dateLayouts {
+			if date, err := time.Parse(layout, v); err == nil {
+				return date, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
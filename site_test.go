@@ -0,0 +1,105 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFirstHeading(t *testing.T) {
+	initMDProcessor(false, "", MarkdownConfig{})
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		meta    map[string]interface{}
+		want    string
+	}{
+		{"markdown", "post.md", "text\n\n# Hello *there*\n\n# Other", nil, "Hello there"},
+		{"markdown without a heading", "post.md", "## Sub", nil, ""},
+		{"markdown template", "post.md", "# {{ .Meta.name }}\n\n# Next", nil, "Next"},
+		{"markdown shortcode", "post.md", "# {{< icon >}}", nil, ""},
+		{"markdown raw", "post.md", "# {{ x }}", map[string]interface{}{"raw": true}, "{{ x }}"},
+		{"html", "post.html", "<H1 class=\"a\">Hello <b>there</b></H1>", nil, "Hello there"},
+		{"html template", "post.html", "<h1>{{ .Page.Title }}</h1><h1>Next</h1>", nil, "Next"},
+		{"html template only", "post.html", "<h1>{{ .Page.Title }}</h1>", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			af := &AlvuFile{
+				sourcePath:       "pages/" + tt.file,
+				name:             tt.file,
+				meta:             tt.meta,
+				writeableContent: []byte(tt.content),
+			}
+			if got := af.firstHeading(); got != tt.want {
+				t.Errorf("firstHeading() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPageTree(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		// `Title: pages [sections]` of the root section and the ones nested in it
+		want string
+	}{
+		{
+			name:  "root index page",
+			files: []string{"index.md", "about.md", "404.html"},
+			want:  "Home: About, Home",
+		},
+		{
+			name:  "root section",
+			files: []string{"_index.md", "about.md"},
+			want:  "Home: About",
+		},
+		{
+			name:  "nested sections",
+			files: []string{"_index.md", "blog/_index.md", "blog/post.md", "blog/2024/old.md", "docs/intro.md"},
+			want:  "Home: Intro [Blog: Old, Post]",
+		},
+		{
+			name:  "weights and prefixes",
+			files: []string{"c.md", "02-b.md", "01-a.md"},
+			want:  "Home: A, B, C",
+		},
+	}
+
+	var describe func(section *PageData) string
+	describe = func(section *PageData) string {
+		pages := []string{}
+		for _, page := range section.Pages {
+			pages = append(pages, page.Title)
+		}
+		described := section.Title + ": " + strings.Join(pages, ", ")
+		for _, nested := range section.Sections {
+			described += " [" + describe(nested) + "]"
+		}
+		return described
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			al := &Alvu{pagesPath: "pages"}
+			for _, name := range tt.files {
+				af := al.NewFile("pages/"+name, name)
+				af.meta = map[string]interface{}{}
+				al.AddFile(af)
+			}
+			al.BuildPageTree()
+
+			if got := describe(al.root); got != tt.want {
+				t.Errorf("BuildPageTree() = %q, want %q", got, tt.want)
+			}
+			for _, af := range al.files {
+				if af.page.Parent == nil && af.page != al.root {
+					t.Errorf("%v has no parent", af.sourcePath)
+				}
+			}
+		})
+	}
+}
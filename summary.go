@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"golang.org/x/net/html"
+)
+
+// summaryMarker is what the `<!--more-->` line is rendered as, so it
+// can't be mistaken for a comment that's part of the content
+const summaryMarker = "<!--alvu-more-->"
+
+// voidElements don't have a closing tag
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"source": true, "track": true, "wbr": true,
+}
+
+// SummaryConfig is the `summary` key of the site config
+type SummaryConfig struct {
+	// length of the summaries that are taken from the content
+	Words int `json:"words"`
+}
+
+// Summary is the start of the content of a markdown page, it's
+// empty while the page itself is being rendered
+func (p *PageData) Summary() template.HTML {
+	if rendered := p.rendered(); rendered != nil {
+		return rendered.summary
+	}
+	return ""
+}
+
+// Truncated is true when the page has more content than its summary
+func (p *PageData) Truncated() bool {
+	if rendered := p.rendered(); rendered != nil {
+		return rendered.truncated
+	}
+	return false
+}
+
+// summaryMarkerTransformer swaps the first `<!--more-->` line of
+// the markdown for a node that marks where the summary ends
+type summaryMarkerTransformer struct{}
+
+var kindSummaryMarker = ast.NewNodeKind("SummaryMarker")
+
+type summaryMarkerNode struct {
+	ast.BaseBlock
+}
+
+func (n *summaryMarkerNode) Kind() ast.NodeKind {
+	return kindSummaryMarker
+}
+
+func (n *summaryMarkerNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+func (t *summaryMarkerTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	source := reader.Source()
+	var marker *ast.HTMLBlock
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		block, ok := n.(*ast.HTMLBlock)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		var raw bytes.Buffer
+		for i := 0; i < block.Lines().Len(); i++ {
+			line := block.Lines().At(i)
+			raw.Write(line.Value(source))
+		}
+		if string(bytes.TrimSpace(raw.Bytes())) == "<!--more-->" {
+			marker = block
+			return ast.WalkStop, nil
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	if marker != nil {
+		marker.Parent().ReplaceChild(marker.Parent(), marker, &summaryMarkerNode{})
+	}
+}
+
+// summaryMarkerRenderer writes the marker whether raw HTML
+// is allowed or not, it's taken out with the summary
+type summaryMarkerRenderer struct{}
+
+func (r *summaryMarkerRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindSummaryMarker, func(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			_, _ = w.WriteString(summaryMarker + "\n")
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// splitSummary takes the summary from the rendered content, which is
+// the content before the `<!--more-->` line, the `summary` in the
+// frontmatter or the first words of the content. The content is
+// returned with the marker put back as it was
+func (af *AlvuFile) splitSummary(content []byte) ([]byte, template.HTML, bool, error) {
+	if before, after, ok := bytes.Cut(content, []byte(summaryMarker)); ok {
+		// the marker can be nested in a list or a quote
+		summary, _ := truncateHTML(before, -1)
+		content = bytes.Replace(content, []byte(summaryMarker), []byte("<!--more-->"), 1)
+		return content, template.HTML(bytes.TrimSpace(summary)), hasText(after), nil
+	}
+
+	if summary, ok := af.meta["summary"].(string); ok {
+		md, err := af.markdownProcessor()
+		if err != nil {
+			return nil, "", false, err
+		}
+		var buf bytes.Buffer
+		if err := md.Convert([]byte(summary), &buf); err != nil {
+			return nil, "", false, err
+		}
+		return content, template.HTML(bytes.TrimSpace(buf.Bytes())), true, nil
+	}
+
+	summary, truncated := truncateHTML(content, af.alvu.config.Summary.Words)
+	return content, template.HTML(bytes.TrimSpace(summary)), truncated, nil
+}
+
+// hasText is true if there's anything other
+// than whitespace and closing tags in the HTML
+func hasText(content []byte) bool {
+	tokenizer := html.NewTokenizer(bytes.NewReader(content))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return false
+		case html.EndTagToken, html.CommentToken:
+		case html.TextToken:
+			if len(bytes.TrimSpace(tokenizer.Raw())) > 0 {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+}
+
+// truncateHTML keeps the first words of the HTML and closes the
+// elements that are still open at the cut, elements opened after
+// the last word are left out. With a negative number of words it
+// only closes the elements left open at the end
+func truncateHTML(content []byte, words int) ([]byte, bool) {
+	var buf bytes.Buffer
+	open := []string{}
+	count := 0
+	// where to cut once all the words are in
+	cutAt, cutOpen := -1, []string(nil)
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(content))
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			if tokenizer.Err() != io.EOF {
+				return content, false
+			}
+			for i := len(open) - 1; i >= 0; i-- {
+				buf.WriteString("</" + open[i] + ">")
+			}
+			return buf.Bytes(), false
+		case html.StartTagToken:
+			if count == words && cutAt == -1 {
+				cutAt, cutOpen = buf.Len(), append([]string{}, open...)
+			}
+			// the tag name is lowercased in place
+			buf.Write(tokenizer.Raw())
+			name, _ := tokenizer.TagName()
+			if !voidElements[string(name)] {
+				open = append(open, string(name))
+			}
+		case html.EndTagToken:
+			buf.Write(tokenizer.Raw())
+			name, _ := tokenizer.TagName()
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == string(name) {
+					open = open[:i]
+					break
+				}
+			}
+		case html.TextToken:
+			text := tokenizer.Raw()
+			cut := -1
+			inWord := false
+			for i, r := range string(text) {
+				isSpace := unicode.IsSpace(r)
+				if !isSpace && !inWord {
+					if count == words {
+						cut = i
+						break
+					}
+					count++
+				}
+				inWord = !isSpace
+			}
+			if cut == -1 {
+				buf.Write(text)
+				continue
+			}
+
+			if cutAt != -1 {
+				buf.Truncate(cutAt)
+				open = cutOpen
+			} else {
+				buf.Write(text[:cut])
+			}
+			buf.Truncate(len(bytes.TrimRightFunc(buf.Bytes(), unicode.IsSpace)))
+			for i := len(open) - 1; i >= 0; i-- {
+				buf.WriteString("</" + open[i] + ">")
+			}
+			return buf.Bytes(), true
+		default:
+			buf.Write(tokenizer.Raw())
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTruncateHTML(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		words     int
+		want      string
+		truncated bool
+	}{
+		{
+			name:    "shorter than the limit",
+			content: "<p>one two</p>",
+			words:   5,
+			want:    "<p>one two</p>",
+		},
+		{
+			name:    "exactly the limit",
+			content: "<p>one two three</p>\n",
+			words:   3,
+			want:    "<p>one two three</p>\n",
+		},
+		{
+			name:      "cut in a paragraph",
+			content:   "<p>one two three four</p>",
+			words:     2,
+			want:      "<p>one two</p>",
+			truncated: true,
+		},
+		{
+			name:      "nested elements are closed in order",
+			content:   "<ul><li><strong>one two</strong> three</li></ul>",
+			words:     1,
+			want:      "<ul><li><strong>one</strong></li></ul>",
+			truncated: true,
+		},
+		{
+			name:      "closed elements stay closed",
+			content:   "<p><em>one</em> two</p><p>three</p>",
+			words:     2,
+			want:      "<p><em>one</em> two</p>",
+			truncated: true,
+		},
+		{
+			name:      "elements opened after the last word are left out",
+			content:   "<p>one two <b>three</b></p>",
+			words:     2,
+			want:      "<p>one two</p>",
+			truncated: true,
+		},
+		{
+			name:      "void elements aren't closed",
+			content:   "<p>one<br>two <img src=\"a.png\"> three</p>",
+			words:     2,
+			want:      "<p>one<br>two</p>",
+			truncated: true,
+		},
+		{
+			name:      "attributes and case are kept",
+			content:   "<P CLASS=\"Lead\">one two</P>",
+			words:     1,
+			want:      "<P CLASS=\"Lead\">one</p>",
+			truncated: true,
+		},
+		{
+			name:      "entities are part of the word",
+			content:   "<p>fish&amp;chips and more</p>",
+			words:     1,
+			want:      "<p>fish&amp;chips</p>",
+			truncated: true,
+		},
+		{
+			name:      "comments are kept",
+			content:   "<p><!-- note -->one two</p>",
+			words:     1,
+			want:      "<p><!-- note -->one</p>",
+			truncated: true,
+		},
+		{
+			name:      "whitespace across lines",
+			content:   "<p>one\n  two\n\tthree</p>",
+			words:     2,
+			want:      "<p>one\n  two</p>",
+			truncated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := truncateHTML([]byte(tt.content), tt.words)
+			if string(got) != tt.want || truncated != tt.truncated {
+				t.Errorf("truncateHTML(%q, %v) = %q, %v, want %q, %v", tt.content, tt.words, got, truncated, tt.want, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestSummary(t *testing.T) {
+	initMDProcessor(false, "", MarkdownConfig{})
+
+	tests := []struct {
+		name      string
+		content   string
+		meta      map[string]interface{}
+		want      string
+		truncated bool
+	}{
+		{
+			name:      "marker",
+			content:   "one two\n\n<!--more-->\n\nthree",
+			want:      "<p>one two</p>",
+			truncated: true,
+		},
+		{
+			name:      "marker with whitespace after it",
+			content:   "one\n\n<!--more-->  \n\nthree",
+			want:      "<p>one</p>",
+			truncated: true,
+		},
+		{
+			name:    "marker at the end",
+			content: "one two\n\n<!--more-->\n",
+			want:    "<p>one two</p>",
+		},
+		{
+			name:      "marker in the middle of a line",
+			content:   "one <!--more--> two three four",
+			want:      "<p>one <!--more--> two three</p>",
+			truncated: true,
+		},
+		{
+			name:      "marker in fenced code",
+			content:   "one\n```\n<!--more-->\n```\ntwo three four",
+			want:      "<p>one</p>\n<pre><code>&lt;!--more--&gt;\n</code></pre>\n<p>two</p>",
+			truncated: true,
+		},
+		{
+			name:      "marker in a list",
+			content:   "- one\n\n  <!--more-->\n- two",
+			want:      "<ul>\n<li>\n<p>one</p>\n</li></ul>",
+			truncated: true,
+		},
+		{
+			name:      "marker over frontmatter",
+			content:   "one\n\n<!--more-->\n\ntwo",
+			meta:      map[string]interface{}{"summary": "other"},
+			want:      "<p>one</p>",
+			truncated: true,
+		},
+		{
+			name:      "frontmatter",
+			content:   "one two three four five",
+			meta:      map[string]interface{}{"summary": "A *custom* summary"},
+			want:      "<p>A <em>custom</em> summary</p>",
+			truncated: true,
+		},
+		{
+			name:    "short content",
+			content: "one two",
+			want:    "<p>one two</p>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			af := &AlvuFile{
+				sourcePath: "pages/post.md",
+				meta:       tt.meta,
+				alvu:       &Alvu{config: &SiteConfig{Summary: SummaryConfig{Words: 3}}},
+			}
+			var rendered bytes.Buffer
+			if err := mdProcessor.Renderer().Render(&rendered, []byte(tt.content), parseMarkdown(mdProcessor, []byte(tt.content), af)); err != nil {
+				t.Fatal(err)
+			}
+
+			content, got, truncated, err := af.splitSummary(rendered.Bytes())
+			if err != nil {
+				t.Fatalf("splitSummary() error = %v", err)
+			}
+			if string(got) != tt.want || truncated != tt.truncated {
+				t.Errorf("splitSummary() = %q, %v, want %q, %v", got, truncated, tt.want, tt.truncated)
+			}
+			if bytes.Contains(content, []byte(summaryMarker)) {
+				t.Errorf("splitSummary() left the marker in %q", content)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// TOCConfig limits the heading levels that
+// end up in the table of contents
+type TOCConfig struct {
+	StartLevel int `json:"start_level"`
+	EndLevel   int `json:"end_level"`
+}
+
+// tocPlaceholderPattern matches the placeholder the table of contents
+// is swapped for while the page itself is being rendered, it's
+// replaced along with the paragraph it ends up in
+var tocPlaceholderPattern = regexp.MustCompile(`(?:<p>)?alvu-table-of-contents-end(?:</p>\n?)?`)
+
+// TOCEntry is a heading of the page, with the
+// headings under it nested as children
+type TOCEntry struct {
+	Level    int         `json:"level"`
+	ID       string      `json:"id"`
+	Title    string      `json:"title"`
+	Children []*TOCEntry `json:"children"`
+}
+
+// extractTOC collects the headings of the parsed markdown document
+// into a nested list, skipped levels are attached to the closest
+// heading above them
+func extractTOC(doc ast.Node, source []byte, config TOCConfig) []*TOCEntry {
+	root := &TOCEntry{Children: []*TOCEntry{}}
+	stack := []*TOCEntry{root}
+
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		heading, ok := n.(*ast.Heading)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+		if heading.Level < config.StartLevel || heading.Level > config.EndLevel {
+			return ast.WalkSkipChildren, nil
+		}
+
+		entry := &TOCEntry{
+			Level:    heading.Level,
+			Title:    strings.TrimSpace(nodeText(heading, source)),
+			Children: []*TOCEntry{},
+		}
+		if id, ok := heading.AttributeString("id"); ok {
+			entry.ID = fmt.Sprintf("%s", id)
+		}
+
+		for len(stack) > 1 && stack[len(stack)-1].Level >= entry.Level {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1]
+		parent.Children = append(parent.Children, entry)
+		stack = append(stack, entry)
+
+		return ast.WalkSkipChildren, nil
+	})
+
+	return root.Children
+}
+
+// TOC is the headings of a markdown page, it's
+// empty while the page itself is being rendered
+func (p *PageData) TOC() []*TOCEntry {
+	if rendered := p.rendered(); rendered != nil {
+		return rendered.toc
+	}
+	return nil
+}
+
+// TableOfContents is the markup for the headings of a markdown page,
+// the page's own content gets a placeholder that's replaced once the
+// headings are known
+func (p *PageData) TableOfContents() template.HTML {
+	if p.file != nil && p.file.isMarkdown() && p.file.alvu != nil && p.file.alvu.rendering == p.file {
+		return "alvu-table-of-contents-end"
+	}
+	if rendered := p.rendered(); rendered != nil {
+		return renderTOC(rendered.toc)
+	}
+	return ""
+}
+
+// renderTOC creates the nested list markup for the entries
+func renderTOC(entries []*TOCEntry) template.HTML {
+	if len(entries) == 0 {
+		return ""
+	}
+
+	var buf strings.Builder
+	var writeEntries func(entries []*TOCEntry)
+	writeEntries = func(entries []*TOCEntry) {
+		buf.WriteString("<ul>")
+		for _, entry := range entries {
+			buf.WriteString(`<li><a href="#` + html.EscapeString(entry.ID) + `">`)
+			buf.WriteString(html.EscapeString(entry.Title))
+			buf.WriteString("</a>")
+			if len(entry.Children) > 0 {
+				writeEntries(entry.Children)
+			}
+			buf.WriteString("</li>")
+		}
+		buf.WriteString("</ul>")
+	}
+
+	buf.WriteString(`<nav class="toc">`)
+	writeEntries(entries)
+	buf.WriteString("</nav>")
+
+	return template.HTML(buf.String())
+}
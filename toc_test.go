@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTableOfContents(t *testing.T) {
+	initMDProcessor(false, "", MarkdownConfig{})
+
+	guide := "## Install\n\n### From source\n\n## Usage\n"
+	tests := []struct {
+		name string
+		// content of pages/_index.md, the guide is one of its pages
+		content string
+		want    string
+	}{
+		{
+			name:    "own table of contents",
+			content: "{{ .Page.TableOfContents }}\n\n## One\n",
+			want:    "<nav class=\"toc\"><ul><li><a href=\"#one\">One</a></li></ul></nav><h2 id=\"one\">One</h2>\n",
+		},
+		{
+			name:    "own headings are empty in the content",
+			content: "{{ len .Page.TOC }}\n\n## One\n",
+			want:    "<p>0</p>\n<h2 id=\"one\">One</h2>\n",
+		},
+		{
+			name:    "no headings",
+			content: "{{ .Page.TableOfContents }}\n\ntext\n",
+			want:    "<p>text</p>\n",
+		},
+		{
+			name:    "headings of another page",
+			content: "{{ range .Page.Pages }}{{ range .TOC }}{{ .Title }} {{ len .Children }}, {{ end }}{{ end }}",
+			want:    "<p>Install 1, Usage 0,</p>\n",
+		},
+		{
+			name:    "table of contents of another page",
+			content: "{{ range .Page.Pages }}{{ .TableOfContents }}{{ end }}",
+			want: "<nav class=\"toc\"><ul><li><a href=\"#install\">Install</a><ul><li><a href=\"#from-source\">From source</a></li></ul></li>" +
+				"<li><a href=\"#usage\">Usage</a></li></ul></nav>",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			al := &Alvu{
+				pagesPath: "pages",
+				config:    &SiteConfig{TOC: TOCConfig{StartLevel: 2, EndLevel: 3}},
+			}
+			for _, name := range []string{"_index.md", "guide.md"} {
+				af := al.NewFile("pages/"+name, name)
+				af.meta = map[string]interface{}{}
+				af.writeableContent = []byte(guide)
+				al.AddFile(af)
+			}
+			al.files[0].writeableContent = []byte(tt.content)
+			al.BuildPageTree()
+
+			rendered := al.files[0].render()
+			if rendered.err != nil {
+				t.Fatal(rendered.err)
+			}
+			if got := strings.TrimPrefix(string(rendered.html), "\n"); got != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// rawBlockPattern matches `{{< raw >}} ... {{< /raw >}}` blocks, the
+// contents of these are never evaluated as templates
+var rawBlockPattern = regexp.MustCompile(`(?s)\{\{<\s*raw\s*>\}\}\r?\n?(.*?)\{\{<\s*/raw\s*>\}\}`)
+
+// blankLinePattern matches the end of a paragraph
+var blankLinePattern = regexp.MustCompile(`\n[ \t]*\r?\n`)
+
+var verbatimPlaceholderPattern = regexp.MustCompile("\x00alvu-verbatim-(\\d+)\x00")
+
+// verbatimStore keeps the sections of content that need to
+// survive the template pass untouched, they are swapped with
+// placeholders before the template is parsed and put back right
+// after it's executed
+type verbatimStore struct {
+	blocks [][]byte
+}
+
+func (vs *verbatimStore) stash(block []byte) []byte {
+	vs.blocks = append(vs.blocks, append([]byte{}, block...))
+	return []byte(fmt.Sprintf("\x00alvu-verbatim-%d\x00", len(vs.blocks)-1))
+}
+
+// Protect replaces raw blocks and, if asked for, fenced code blocks
+// with placeholders
+func (vs *verbatimStore) Protect(content []byte, fences bool) []byte {
+	if fences {
+		var buf bytes.Buffer
+		last := 0
+		for _, fence := range fencedCodeRanges(content) {
+			buf.Write(content[last:fence[0]])
+			buf.Write(vs.stash(content[fence[0]:fence[1]]))
+			last = fence[1]
+		}
+		buf.Write(content[last:])
+		content = buf.Bytes()
+	}
+
+	return rawBlockPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+		inner := rawBlockPattern.FindSubmatch(match)[1]
+		return vs.stash(inner)
+	})
+}
+
+// Restore puts the stashed blocks back in place of their placeholders,
+// raw blocks can contain fences so this is repeated till nothing's left
+func (vs *verbatimStore) Restore(content []byte) []byte {
+	if len(vs.blocks) == 0 {
+		return content
+	}
+	for verbatimPlaceholderPattern.Match(content) {
+		content = verbatimPlaceholderPattern.ReplaceAllFunc(content, func(match []byte) []byte {
+			var index int
+			fmt.Sscanf(string(verbatimPlaceholderPattern.FindSubmatch(match)[1]), "%d", &index)
+			if index >= len(vs.blocks) {
+				return []byte{}
+			}
+			return vs.blocks[index]
+		})
+	}
+	return content
+}
+
+// fencedCodeRanges returns the byte offsets of every fenced code block
+// (``` or ~~~) in the markdown source, including the fences themselves.
+// An unclosed fence runs till the end of the content, same as commonmark
+func fencedCodeRanges(content []byte) [][2]int {
+	ranges := [][2]int{}
+
+	var fenceChar byte
+	fenceLen := 0
+	start := -1
+
+	offset := 0
+	for offset < len(content) {
+		lineEnd := bytes.IndexByte(content[offset:], '\n')
+		if lineEnd == -1 {
+			lineEnd = len(content)
+		} else {
+			lineEnd = offset + lineEnd + 1
+		}
+		line := bytes.TrimRight(content[offset:lineEnd], "\r\n")
+
+		char, size, rest := parseFence(line)
+		if start == -1 {
+			// backtick fences can't have backticks in the info string
+			if size > 0 && !(char == '`' && bytes.IndexByte(rest, '`') != -1) {
+				fenceChar, fenceLen, start = char, size, offset
+			}
+		} else if char == fenceChar && size >= fenceLen && len(bytes.TrimSpace(rest)) == 0 {
+			ranges = append(ranges, [2]int{start, lineEnd})
+			start = -1
+		}
+
+		offset = lineEnd
+	}
+
+	if start != -1 {
+		ranges = append(ranges, [2]int{start, len(content)})
+	}
+
+	return ranges
+}
+
+// verbatimRanges returns the byte offsets of the fenced
+// code and raw blocks in the content
+func verbatimRanges(content []byte) [][2]int {
+	ranges := fencedCodeRanges(content)
+	for _, loc := range rawBlockPattern.FindAllIndex(content, -1) {
+		ranges = append(ranges, [2]int{loc[0], loc[1]})
+	}
+	return ranges
+}
+
+// inlineCodeRanges returns the byte offsets of the code spans in the
+// markdown source, including the backticks and leaving out the ones
+// in the skipped ranges. A span ends at the next run of the same number
+// of backticks in the same paragraph, a run without one is just text
+func inlineCodeRanges(content []byte, skip [][2]int) [][2]int {
+	ranges := [][2]int{}
+
+	skipped := func(index int) int {
+		for _, r := range skip {
+			if index >= r[0] && index < r[1] {
+				return r[1]
+			}
+		}
+		return -1
+	}
+	runLength := func(index int) int {
+		size := 0
+		for index+size < len(content) && content[index+size] == '`' {
+			size++
+		}
+		return size
+	}
+
+	offset := 0
+	for offset < len(content) {
+		index := bytes.IndexByte(content[offset:], '`')
+		if index == -1 {
+			break
+		}
+		index += offset
+		if end := skipped(index); end != -1 {
+			offset = end
+			continue
+		}
+		if index > 0 && content[index-1] == '\\' {
+			// an escaped backtick can't open a span
+			offset = index + 1
+			continue
+		}
+
+		size := runLength(index)
+		offset = index + size
+		for search := offset; search < len(content); {
+			next := bytes.IndexByte(content[search:], '`')
+			if next == -1 {
+				break
+			}
+			next += search
+			if skipped(next) != -1 || blankLinePattern.Match(content[index:next]) {
+				break
+			}
+			closing := runLength(next)
+			if closing == size {
+				ranges = append(ranges, [2]int{index, next + closing})
+				offset = next + closing
+				break
+			}
+			search = next + closing
+		}
+	}
+
+	return ranges
+}
+
+// parseFence checks if the line is a code fence and returns
+// the fence character, the length of the fence and whatever follows it
+func parseFence(line []byte) (byte, int, []byte) {
+	indent := 0
+	for indent < len(line) && line[indent] == ' ' {
+		indent++
+	}
+	if indent > 3 || indent >= len(line) {
+		return 0, 0, nil
+	}
+
+	char := line[indent]
+	if char != '`' && char != '~' {
+		return 0, 0, nil
+	}
+
+	size := 0
+	for indent+size < len(line) && line[indent+size] == char {
+		size++
+	}
+	if size < 3 {
+		return 0, 0, nil
+	}
+
+	return char, size, line[indent+size:]
+}
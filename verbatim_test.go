@@ -0,0 +1,145 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFencedCodeRanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "no fences",
+			content: "# Title\n\nsome `code` here\n",
+			want:    []string{},
+		},
+		{
+			name:    "backticks",
+			content: "before\n```go\n{{ .X }}\n```\nafter\n",
+			want:    []string{"```go\n{{ .X }}\n```\n"},
+		},
+		{
+			name:    "tildes",
+			content: "~~~\n{{ .X }}\n~~~\n",
+			want:    []string{"~~~\n{{ .X }}\n~~~\n"},
+		},
+		{
+			name:    "longer closing fence",
+			content: "```\ncode\n`````\nafter\n",
+			want:    []string{"```\ncode\n`````\n"},
+		},
+		{
+			name:    "shorter fence doesn't close",
+			content: "````\n```\nstill code\n````\n",
+			want:    []string{"````\n```\nstill code\n````\n"},
+		},
+		{
+			name:    "other fence character doesn't close",
+			content: "```\n~~~\ncode\n```\n",
+			want:    []string{"```\n~~~\ncode\n```\n"},
+		},
+		{
+			name:    "closing fence with text doesn't close",
+			content: "```\n``` not yet\n```\n",
+			want:    []string{"```\n``` not yet\n```\n"},
+		},
+		{
+			name:    "unclosed runs till the end",
+			content: "text\n```\n{{ .X }}\n",
+			want:    []string{"```\n{{ .X }}\n"},
+		},
+		{
+			name:    "indented up to three spaces",
+			content: "   ```\ncode\n   ```\n",
+			want:    []string{"   ```\ncode\n   ```\n"},
+		},
+		{
+			name:    "four spaces is not a fence",
+			content: "    ```\ncode\n",
+			want:    []string{},
+		},
+		{
+			name:    "backticks in the info string",
+			content: "``` a`b\ncode\n",
+			want:    []string{},
+		},
+		{
+			name:    "two blocks",
+			content: "```\na\n```\ntext\n~~~\nb\n~~~",
+			want:    []string{"```\na\n```\n", "~~~\nb\n~~~"},
+		},
+		{
+			name:    "crlf",
+			content: "```\r\ncode\r\n```\r\nafter\r\n",
+			want:    []string{"```\r\ncode\r\n```\r\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := []string{}
+			for _, r := range fencedCodeRanges([]byte(tt.content)) {
+				got = append(got, tt.content[r[0]:r[1]])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fencedCodeRanges(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerbatimStore(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		fences  bool
+		// `{{ .B }}` is kept out of the template pass
+		hidden   bool
+		restored string
+	}{
+		{
+			name:     "fences",
+			content:  "{{ .A }}\n```\n{{ .B }}\n```\n",
+			fences:   true,
+			hidden:   true,
+			restored: "{{ .A }}\n```\n{{ .B }}\n```\n",
+		},
+		{
+			name:     "fences left for html",
+			content:  "```\n{{ .B }}\n```\n",
+			fences:   false,
+			restored: "```\n{{ .B }}\n```\n",
+		},
+		{
+			name:     "raw block",
+			content:  "a {{< raw >}}{{ .B }}{{< /raw >}} c",
+			fences:   true,
+			hidden:   true,
+			restored: "a {{ .B }} c",
+		},
+		{
+			name:     "fence in a raw block",
+			content:  "{{< raw >}}\n```\n{{ .B }}\n```\n{{< /raw >}}",
+			fences:   true,
+			hidden:   true,
+			restored: "```\n{{ .B }}\n```\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vs := &verbatimStore{}
+			protected := vs.Protect([]byte(tt.content), tt.fences)
+			if hidden := !strings.Contains(string(protected), "{{ .B }}"); hidden != tt.hidden {
+				t.Errorf("Protect(%q) = %q, hidden %v, want %v", tt.content, protected, hidden, tt.hidden)
+			}
+			if got := string(vs.Restore(protected)); got != tt.restored {
+				t.Errorf("Restore(Protect(%q)) = %q, want %q", tt.content, got, tt.restored)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// wikiLinkPattern matches `[[Page]]`, `[[Page#Heading]]` and `[[Page|label]]`
+var wikiLinkPattern = regexp.MustCompile(`\[\[([^\[\]\n|]+)(?:\|([^\[\]\n]*))?\]\]`)
+
+// PageLink is a page as it's passed to hooks
+type PageLink struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// KindMissingWikiLink is a wiki link to a page that doesn't exist
+var KindMissingWikiLink = ast.NewNodeKind("MissingWikiLink")
+
+// MissingWikiLink holds the label of a wiki link that
+// couldn't be resolved
+type MissingWikiLink struct {
+	ast.BaseInline
+	Target string
+}
+
+func (n *MissingWikiLink) Kind() ast.NodeKind {
+	return KindMissingWikiLink
+}
+
+func (n *MissingWikiLink) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.Target}, nil)
+}
+
+// wikiLinkExtension turns `[[Page]]` into links to the page with
+// that title or file name, links to pages that don't exist are
+// rendered as a `wikilink-missing` span and reported
+type wikiLinkExtension struct{}
+
+func (e *wikiLinkExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithInlineParsers(
+		// before the link parser
+		util.Prioritized(&wikiLinkParser{}, 199),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&wikiLinkRenderer{}, 500),
+	))
+}
+
+type wikiLinkParser struct{}
+
+func (p *wikiLinkParser) Trigger() []byte {
+	return []byte{'['}
+}
+
+func (p *wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	af, ok := pc.Get(linkFileKey).(*AlvuFile)
+	if !ok || af == nil || af.alvu == nil {
+		return nil
+	}
+
+	line, segment := block.PeekLine()
+	match := wikiLinkPattern.FindSubmatchIndex(line)
+	if match == nil || match[0] != 0 {
+		return nil
+	}
+	block.Advance(match[1])
+
+	target := string(line[match[2]:match[3]])
+	label := text.NewSegment(segment.Start+match[2], segment.Start+match[3])
+	if match[4] != -1 && match[5] > match[4] {
+		label = text.NewSegment(segment.Start+match[4], segment.Start+match[5])
+	}
+
+	destination, found := af.resolveWikiLink(target)
+	if !found {
+		af.brokenLinks = append(af.brokenLinks, "[["+target+"]]")
+		missing := &MissingWikiLink{Target: target}
+		missing.AppendChild(missing, ast.NewTextSegment(label))
+		return missing
+	}
+
+	link := ast.NewLink()
+	link.Destination = []byte(destination)
+	link.SetAttributeString("class", []byte("wikilink"))
+	link.AppendChild(link, ast.NewTextSegment(label))
+	return link
+}
+
+type wikiLinkRenderer struct{}
+
+func (r *wikiLinkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMissingWikiLink, r.renderMissingWikiLink)
+}
+
+func (r *wikiLinkRenderer) renderMissingWikiLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		_, _ = w.WriteString(`<span class="wikilink-missing">`)
+	} else {
+		_, _ = w.WriteString("</span>")
+	}
+	return ast.WalkContinue, nil
+}
+
+// resolveWikiLink returns the url of the page a wiki link points
+// to, with the heading after the `#` as the fragment
+func (af *AlvuFile) resolveWikiLink(target string) (string, bool) {
+	name, heading, _ := strings.Cut(target, "#")
+
+	page := af
+	if strings.TrimSpace(name) != "" {
+		page = af.alvu.wikiTargets[wikiKey(name)]
+	}
+	if page == nil {
+		return "", false
+	}
+
+	destination := page.URL()
+	if heading = strings.TrimSpace(heading); heading != "" {
+		destination += "#" + string(newHeadingIDs(nil).Generate([]byte(heading), ast.KindHeading))
+	}
+	return destination, true
+}
+
+// BuildWikiLinks indexes the pages by their path, title and file
+// name and collects the wiki links between them as backlinks
+func (al *Alvu) BuildWikiLinks() {
+	al.wikiTargets = map[string]*AlvuFile{}
+	add := func(name string, af *AlvuFile) {
+		key := wikiKey(name)
+		if _, ok := al.wikiTargets[key]; !ok && key != "" {
+			al.wikiTargets[key] = af
+		}
+	}
+
+	// paths are the most specific, then titles and file names
+	for _, af := range al.files {
+		if af.page == nil || !af.isListed() {
+			continue
+		}
+		rel, err := filepath.Rel(al.pagesPath, af.sourcePath)
+		if err != nil {
+			continue
+		}
+		rel = strings.TrimSuffix(filepath.ToSlash(rel), filepath.Ext(rel))
+		if filepath.Base(af.sourcePath) == sectionIndexName {
+			rel = strings.TrimSuffix(rel, "/"+strings.TrimSuffix(sectionIndexName, ".md"))
+		}
+		add(rel, af)
+		add(stripOrderPrefixes(rel), af)
+	}
+	for _, af := range al.files {
+		if af.page != nil && af.isListed() {
+			add(af.page.Title, af)
+		}
+	}
+	for _, af := range al.files {
+		if af.page != nil && af.isListed() {
+			add(af.orderedName(), af)
+			if _, name, ok := splitOrderPrefix(af.orderedName()); ok {
+				add(name, af)
+			}
+		}
+	}
+
+	for _, af := range al.files {
+		if af.page != nil {
+			af.page.Backlinks = []*PageData{}
+		}
+	}
+	for _, af := range al.files {
+		if af.page == nil || !af.isMarkdown() {
+			continue
+		}
+		linked := map[*AlvuFile]bool{}
+		for _, target := range wikiLinkTargets(af.writeableContent) {
+			name, _, _ := strings.Cut(target, "#")
+			page := al.wikiTargets[wikiKey(name)]
+			if page == nil || page == af || linked[page] {
+				continue
+			}
+			linked[page] = true
+			page.page.Backlinks = append(page.page.Backlinks, af.page)
+		}
+	}
+	for _, af := range al.files {
+		if af.page != nil {
+			sort.SliceStable(af.page.Backlinks, func(i, j int) bool {
+				return af.page.Backlinks[i].Title < af.page.Backlinks[j].Title
+			})
+		}
+	}
+}
+
+// wikiLinkTargets returns the targets of the wiki links in the
+// markdown, leaving out the ones in fenced and inline code
+func wikiLinkTargets(content []byte) []string {
+	skip := fencedCodeRanges(content)
+	skip = append(skip, inlineCodeRanges(content, skip)...)
+	targets := []string{}
+	for _, match := range wikiLinkPattern.FindAllSubmatchIndex(content, -1) {
+		skipped := false
+		for _, r := range skip {
+			if match[0] >= r[0] && match[0] < r[1] {
+				skipped = true
+				break
+			}
+		}
+		if !skipped {
+			targets = append(targets, string(content[match[2]:match[3]]))
+		}
+	}
+	return targets
+}
+
+// wikiKey normalizes a page name so `Getting Started`,
+// `getting-started` and `getting_started` are the same page
+func wikiKey(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	name = strings.NewReplacer("-", " ", "_", " ").Replace(name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// pageLinks converts pages into what's passed to hooks
+func pageLinks(pages []*PageData) []PageLink {
+	links := []PageLink{}
+	for _, page := range pages {
+		links = append(links, PageLink{Title: page.Title, URL: page.URL})
+	}
+	return links
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWikiLinkTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"page", "see [[Install]] and [[usage#Flags|the flags]]", []string{"Install", "usage#Flags"}},
+		{"empty label", "[[Install|]]", []string{"Install"}},
+		{"not across lines", "[[Inst\nall]]", []string{}},
+		{"fenced code", "```\n[[Install]]\n```\n[[Usage]]", []string{"Usage"}},
+		{"inline code", "`[[Install]]` and ``a [[Setup]] b`` [[Usage]]", []string{"Usage"}},
+		{"unclosed backtick", "`[[Install]]", []string{"Install"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := wikiLinkTargets([]byte(tt.content)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("wikiLinkTargets(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWikiLinks(t *testing.T) {
+	initMDProcessor(false, "", MarkdownConfig{})
+
+	al := &Alvu{pagesPath: "pages"}
+	contents := map[string]string{
+		"01-install.md":    "---\ntitle: Getting Started\n---\nsee [[usage]]",
+		"usage.md":         "back to [[Getting Started]], `[[usage]]` and [[install]]",
+		"guides/cli.md":    "[[guides/cli#Flags]]",
+		"guides/_index.md": "",
+	}
+	for _, name := range []string{"01-install.md", "usage.md", "guides/cli.md", "guides/_index.md"} {
+		af := al.NewFile("pages/"+name, name)
+		af.content = []byte(contents[name])
+		if err := af.ParseMeta(); err != nil {
+			t.Fatal(err)
+		}
+		al.AddFile(af)
+	}
+	al.BuildPageTree()
+	al.BuildWikiLinks()
+
+	tests := []struct {
+		name   string
+		target string
+		want   string
+		found  bool
+	}{
+		{"title", "Getting Started", "/install/", true},
+		{"file name", "01-install", "/install/", true},
+		{"file name without the prefix", "install", "/install/", true},
+		{"case and spaces", " getting   started ", "/install/", true},
+		{"path", "guides/cli", "/guides/cli/", true},
+		{"section", "guides", "/guides/", true},
+		{"heading", "usage#Some Flags", "/usage/#some-flags", true},
+		{"heading on the same page", "#Flags", "/guides/cli/#flags", true},
+		{"missing", "nope", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := al.files[2].resolveWikiLink(tt.target)
+			if got != tt.want || found != tt.found {
+				t.Errorf("resolveWikiLink(%q) = %q, %v, want %q, %v", tt.target, got, found, tt.want, tt.found)
+			}
+		})
+	}
+
+	backlinks := func(af *AlvuFile) string {
+		titles := []string{}
+		for _, page := range af.page.Backlinks {
+			titles = append(titles, page.Title)
+		}
+		return strings.Join(titles, ", ")
+	}
+	if got := backlinks(al.files[0]); got != "Usage" {
+		t.Errorf("backlinks of install = %q, want %q", got, "Usage")
+	}
+	// the link in inline code and to itself aren't counted
+	if got := backlinks(al.files[1]); got != "Getting Started" {
+		t.Errorf("backlinks of usage = %q, want %q", got, "Getting Started")
+	}
+
+	var buf bytes.Buffer
+	source := []byte("[[usage|Usage page]] `[[usage]]` [[nope]]")
+	if err := mdProcessor.Renderer().Render(&buf, source, parseMarkdown(mdProcessor, source, al.files[0])); err != nil {
+		t.Fatal(err)
+	}
+	want := "<p><a href=\"/usage/\" class=\"wikilink\">Usage page</a> <code>[[usage]]</code> <span class=\"wikilink-missing\">nope</span></p>\n"
+	if buf.String() != want {
+		t.Errorf("Render() = %q, want %q", buf.String(), want)
+	}
+	if !reflect.DeepEqual(al.files[0].brokenLinks, []string{"[[nope]]"}) {
+		t.Errorf("brokenLinks = %q, want %q", al.files[0].brokenLinks, []string{"[[nope]]"})
+	}
+}